@@ -7,13 +7,13 @@ toolchain go1.24.11
 require (
 	github.com/gdamore/tcell/v2 v2.13.5
 	github.com/rivo/tview v0.42.0
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/sys v0.39.0
 )
 
 require (
 	github.com/gdamore/encoding v1.0.1 // indirect
 	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
-	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/term v0.38.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 )
@@ -2,80 +2,356 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	statusTimeout = "timeout"
+	statusCancelled = "cancelled"
+	statusDryRun = "dry-run"
+	exitCodeTimeout = 124
+	exitCodeCancelled = 130
+	killDelay = 2 * time.Second
+	exitCodeNotStarted = 127
+	stderrTailSize = 4096
 )
 
-func RunCommand(match string) (string, string, string) {
-	args := prepareArguments(match)
-	cmd := exec.Command(config.program, args...)
+type Result struct {
+	start time.Time
+	command string
+	output string
+	exitStatus string
+	message string
+}
+
+// Keeps only the end of everything written to it
+type tailBuffer struct {
+	data []byte
+}
+
+// Exit code of this program summarizing all executed commands
+var sessionExitCode = 0
+
+// All commands executed in this session
+var history = []*Result{}
+
+var reShellSafe = regexp.MustCompile("^[A-Za-z0-9_./:@%+=,-]+$")
+var rePlaceholder = regexp.MustCompile("\\$?\\{[^{}]*\\}")
+
+// Placeholders like {n} in the arguments of COMMAND, which are replaced besides {}
+type Placeholders map[string]string
+
+func RunCommand(match string, placeholders Placeholders, env []string) *Result {
+	program, args := prepareCommand(match, placeholders)
+
+	ctx, cancel := timeoutContext()
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Env = append(os.Environ(), env...)
 
 	// Try re-attaching stdin to /dev/tty because of pipe input
 	stdin, err := os.Open("/dev/tty")
+	foreground := err == nil && !config.showProgramOutput
 	if err == nil {
 		cmd.Stdin = stdin
 		defer stdin.Close()
 	} else {
 		cmd.Stdin = os.Stdin
 	}
+	if foreground {
+		// Interactive commands like less get the terminal and handle Ctrl-C themselves
+		cmd.SysProcAttr = &syscall.SysProcAttr{Foreground: true, Ctty: int(stdin.Fd())}
+		defer takeForeground(stdin)
+	}
 
+	// The end of stderr is kept to explain errors
 	var buffer bytes.Buffer
+	var stderr tailBuffer
 	if config.showProgramOutput {
-		cmd.Stdout = &buffer
-		cmd.Stderr = &buffer
+		output := io.MultiWriter(&buffer, &stderr)
+		cmd.Stdout = output
+		cmd.Stderr = output
 	} else {
 		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 	}
 
-	exitCode := 0
-	err = cmd.Run()
+	// Ctrl-C cancels the command instead of terminating this program, unless the command has the terminal
+	var cancelled atomic.Bool
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			if !foreground {
+				cancelled.Store(true)
+				cancel()
+			}
+		case <-ctx.Done():
+		}
+	}()
 
-	if err != nil {
+	result := &Result{
+		start: time.Now(),
+		command: PrintCommand(match, placeholders),
+		exitStatus: "0",
+	}
+	history = append(history, result)
+	err = runProcessGroup(cmd)
+
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The command succeeded, but left its output open in a background process
+		err = nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		result.exitStatus = statusTimeout
+	} else if cancelled.Load() || isInterrupted(err) {
+		result.exitStatus = statusCancelled
+	} else if err != nil {
 		// Try to forward the command's exit code
 		exitError, ok := err.(*exec.ExitError)
 		if ok {
-			exitCode = exitError.ExitCode()
-			if !config.ignoreProgramError {
-				os.Exit(exitCode)
-			}
+			result.exitStatus = strconv.Itoa(exitError.ExitCode())
+			result.message = stderr.LastLine()
 		} else {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+			// The command could not be started at all
+			result.exitStatus = strconv.Itoa(exitCodeNotStarted)
+			result.message = err.Error()
 		}
 	}
 
-	return PrintCommand(match), buffer.String(), strconv.Itoa(exitCode)
+	if config.record {
+		RecordHistory(match, placeholders, result)
+	}
+
+	if result.Failed() && !config.ignoreProgramError {
+		if config.exitOnProgramError {
+			if result.message != "" && (config.showProgramOutput || cmd.ProcessState == nil) {
+				// Otherwise the message has already been printed by the command
+				fmt.Fprintln(os.Stderr, result.message)
+			}
+			os.Exit(exitCode(result.exitStatus))
+		}
+		sessionExitCode = exitCode(result.exitStatus)
+	}
+
+	result.output = buffer.String()
+	return result
 }
 
-func PrintCommand(match string) string {
+// Returns a context which is done after --timeout, if set
+func timeoutContext() (context.Context, context.CancelFunc) {
+	if config.timeout > 0 {
+		return context.WithTimeout(context.Background(), config.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// Runs the command in its own process group, which is interrupted as a whole when the context is done.
+// The command is killed if it does not terminate in time, and so are its remaining child processes
+func runProcessGroup(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cancelled := false
+	cmd.Cancel = func() error {
+		cancelled = true
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+	}
+	cmd.WaitDelay = killDelay
+
+	err := cmd.Run()
+	if cancelled {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return err
+}
+
+// Takes the terminal back from a command in the foreground
+func takeForeground(tty *os.File) {
+	// Changing the foreground process group from the background would stop this program otherwise
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	unix.IoctlSetPointerInt(int(tty.Fd()), unix.TIOCSPGRP, unix.Getpgrp())
+}
+
+func (result *Result) Failed() bool {
+	return result.exitStatus != "0" && result.exitStatus != statusDryRun
+}
+
+func PrintCommand(match string, placeholders Placeholders) string {
 	if match == "" {
 		return ""
 	}
-	args := prepareArguments(match)
+	if config.shell {
+		return prepareShellCommand(match, placeholders)
+	}
+	args := prepareArguments(match, placeholders)
 	return fmt.Sprintf("%s %s", config.program, strings.Join(args, " "))
 }
 
-func prepareArguments(match string) []string {
+func PrintShellCommand(match string, placeholders Placeholders) string {
+	if config.shell {
+		return prepareShellCommand(match, placeholders)
+	}
+
+	// Quote the command to be able to run it again in a shell
+	quoted := []string{shellQuote(config.program)}
+	for _, arg := range prepareArguments(match, placeholders) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+func prepareCommand(match string, placeholders Placeholders) (string, []string) {
+	if !config.shell {
+		return config.program, prepareArguments(match, placeholders)
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return shell, []string{"-c", prepareShellCommand(match, placeholders)}
+}
+
+func prepareShellCommand(match string, placeholders Placeholders) string {
+	// Replace any {} with the quoted match, otherwise append it
+	command, inserted := replacePlaceholders(config.program, match, placeholders, shellQuote)
+	if inserted {
+		return command
+	}
+	return command + " " + shellQuote(match)
+}
+
+func prepareArguments(match string, placeholders Placeholders) []string {
 	args := make([]string, len(config.programArgs))
 	copy(args, config.programArgs)
 
-	// Replace any {} in argument with the match
+	// Replace any {} or other placeholder in argument with the match
 	argInserted := false
 	for i := range args {
-		if strings.Contains(args[i], "{}") {
-			args[i] = strings.ReplaceAll(args[i], "{}", match)
-			argInserted = true
-		}
+		var inserted bool
+		args[i], inserted = replacePlaceholders(args[i], match, placeholders, nil)
+		argInserted = argInserted || inserted
 	}
 
-	// There was no {}, so just append match
+	// There was no placeholder, so just append match
 	if !argInserted {
 		args = append(args, match)
 	}
 
 	return args
 }
+
+// Replaces {} and all placeholders, which are optionally quoted, and returns whether any has been found
+func replacePlaceholders(s string, match string, placeholders Placeholders, quote func(string) string) (string, bool) {
+	if quote == nil {
+		quote = func(value string) string { return value }
+	}
+
+	// Replace in a single pass, so inserted values are never replaced again
+	inserted := false
+	s = rePlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		if strings.HasPrefix(placeholder, "$") {
+			// Shell expansions like ${n} are kept
+			return placeholder
+		}
+		name := placeholder[1 : len(placeholder) - 1]
+		value, ok := placeholders[name]
+		if name == "" {
+			value, ok = match, true
+		}
+		if !ok {
+			// Unknown placeholders like ${HOME} are kept
+			return placeholder
+		}
+		inserted = true
+		return quote(value)
+	})
+	return s, inserted
+}
+
+func shellQuote(s string) string {
+	if reShellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
+
+func isInterrupted(err error) bool {
+	exitError, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	status, ok := exitError.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGINT
+}
+
+func exitCode(exitStatus string) int {
+	switch exitStatus {
+	case statusTimeout:
+		return exitCodeTimeout
+	case statusCancelled:
+		return exitCodeCancelled
+	}
+	code, err := strconv.Atoi(exitStatus)
+	if err != nil {
+		return 1
+	}
+	return code
+}
+
+func (buffer *tailBuffer) Write(p []byte) (int, error) {
+	buffer.data = append(buffer.data, p...)
+	if len(buffer.data) > stderrTailSize {
+		buffer.data = buffer.data[len(buffer.data) - stderrTailSize:]
+	}
+	return len(p), nil
+}
+
+func (buffer *tailBuffer) LastLine() string {
+	lines := strings.Split(strings.TrimSpace(string(buffer.data)), "\n")
+	return strings.TrimSpace(reAnsiColorCodes.ReplaceAllString(lines[len(lines) - 1], ""))
+}
+
+func PrintHistory() string {
+	lines := []string{}
+	for _, result := range history {
+		lines = append(lines, fmt.Sprintf("%s  %-10s  %s", result.start.Format(time.TimeOnly), FormatExitStatus(result.exitStatus), result.command))
+	}
+	if len(lines) == 0 {
+		return "No command has been executed yet"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func FormatExitStatus(exitStatus string) string {
+	switch exitStatus {
+	case statusTimeout:
+		return "Timeout after " + config.timeout.String()
+	case statusCancelled:
+		return "Cancelled"
+	case statusDryRun:
+		return "Not executed (dry run)"
+	}
+	return "Exit=" + exitStatus
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPrintCommand(t *testing.T) {
@@ -9,20 +13,109 @@ func TestPrintCommand(t *testing.T) {
 	config.program = "program"
 	config.programArgs = []string{"arg1", "arg2"}
 
-	cmd := PrintCommand("match")
+	cmd := PrintCommand("match", nil)
 	if cmd != "program arg1 arg2 match" {
 		t.Error("Incorrect command string")
 	}
 
-	cmd = PrintCommand("")
+	cmd = PrintCommand("", nil)
 	if cmd != "" {
 		t.Error("Incorrect empty command string")
 	}
 
 	config.programArgs = []string{"arg1", "{}", "{}{}", "arg{}3", "arg4"}
 
-	cmd = PrintCommand("match")
+	cmd = PrintCommand("match", nil)
 	if cmd != "program arg1 match matchmatch argmatch3 arg4" {
 		t.Error("Incorrect inserted command string")
 	}
+
+	config.programArgs = []string{"-n", "{n}p", "file"}
+
+	cmd = PrintCommand("match", Placeholders{"n": "12"})
+	if cmd != "program -n 12p file" {
+		t.Error("Incorrect command string with placeholder")
+	}
+}
+
+func TestPrintShellCommand(t *testing.T) {
+	config = &Config{}
+	config.shell = true
+	config.program = "git show {} | head -n {}"
+
+	cmd := PrintCommand("abc123", nil)
+	if cmd != "git show abc123 | head -n abc123" {
+		t.Error("Incorrect shell command string")
+	}
+
+	cmd = PrintCommand("it's a file; rm -rf", nil)
+	if cmd != "git show 'it'\\''s a file; rm -rf' | head -n 'it'\\''s a file; rm -rf'" {
+		t.Error("Incorrect quoted shell command string")
+	}
+
+	config.program = "less"
+
+	cmd = PrintCommand("$HOME", nil)
+	if cmd != "less '$HOME'" {
+		t.Error("Incorrect appended shell command string")
+	}
+
+	config.program = "sed -n {n}p {}"
+
+	cmd = PrintCommand("my file", Placeholders{"n": "3"})
+	if cmd != "sed -n 3p 'my file'" {
+		t.Error("Incorrect shell command string with placeholder")
+	}
+}
+
+func TestPlaceholdersInValues(t *testing.T) {
+	config = &Config{}
+	config.program = "echo"
+	config.programArgs = []string{"{V}", "{}", "{n}", "${HOME}", "${n}", "${}"}
+
+	// Inserted values are never replaced again
+	cmd := PrintCommand("{n}", Placeholders{"V": "{} {n}", "n": "2"})
+	if cmd != "echo {} {n} {n} 2 ${HOME} ${n} ${}" {
+		t.Error("Incorrect command string with placeholders in values", cmd)
+	}
+
+	config.shell = true
+	config.program = "echo {V} {}"
+
+	cmd = PrintCommand("a", Placeholders{"V": "{}; {n}", "n": "2"})
+	if cmd != "echo '{}; {n}' a" {
+		t.Error("Incorrect shell command string with placeholders in values", cmd)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	buffer := &tailBuffer{}
+	buffer.Write([]byte("first line\nsecond"))
+	buffer.Write([]byte(" line\n\n"))
+
+	if buffer.LastLine() != "second line" {
+		t.Error("Incorrect last line")
+	}
+
+	buffer.Write([]byte(strings.Repeat("x", stderrTailSize + 1)))
+	if len(buffer.data) != stderrTailSize {
+		t.Error("Incorrect size of tail")
+	}
+}
+
+func TestRunProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+	// The shell waits for its child, which is interrupted as well
+	cmd := exec.CommandContext(ctx, "sh", "-c", "sleep 10; echo done")
+	var output strings.Builder
+	cmd.Stdout = &output
+	start := time.Now()
+	err := runProcessGroup(cmd)
+	if err == nil || output.Len() > 0 {
+		t.Error("Command not interrupted", err)
+	}
+	if time.Since(start) >= killDelay {
+		t.Error("Child process of command not interrupted")
+	}
 }
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -14,13 +19,47 @@ type Config struct {
 	patternFuncInfo string
 	program string
 	programArgs []string
+	shell bool
+	confirm bool
+	dryRun bool
 	filter bool
+	invertFilter bool
+	exclude []*regexp.Regexp
 	sort int
+	sortMode string
+	unique string
+	group bool
+	table string
+	column string
+	jsonl bool
+	display string
+	matchField string
+	header int
+	delimiter string
+	multiLine bool
+	keepEmpty bool
+	files []string
+	inputCommand string
 	showProgramOutput bool
 	ignoreProgramError bool
+	exitOnProgramError bool
+	timeout time.Duration
+	record bool
+	mouse bool
+	wrap bool
+	lineNumbers bool
+	history bool
+	keymap *Keymap
+	noColor bool
 	test bool
 }
 
+type configEntry struct {
+	key string
+	value string
+	line int
+}
+
 func NewConfig() *Config {
 	config = &Config {
 		pattern: nil,
@@ -30,40 +69,219 @@ func NewConfig() *Config {
 		patternFuncInfo: "",
 		program: "",
 		programArgs: []string{},
+		shell: false,
+		confirm: false,
+		dryRun: false,
 		filter: false,
+		invertFilter: false,
+		exclude: []*regexp.Regexp{},
 		sort: 0,
+		sortMode: "",
+		unique: "",
+		group: false,
+		table: "",
+		column: "",
+		jsonl: false,
+		display: "",
+		matchField: "",
+		header: 0,
+		delimiter: "",
+		multiLine: false,
+		keepEmpty: false,
+		files: []string{},
+		inputCommand: "",
 		showProgramOutput: false,
 		ignoreProgramError: false,
+		exitOnProgramError: false,
+		timeout: 0,
+		record: false,
+		mouse: false,
+		wrap: false,
+		lineNumbers: false,
+		history: false,
+		keymap: nil,
+		noColor: os.Getenv("NO_COLOR") != "",
 		test: false,
 	}
 
+	// Settings from the configuration file can be overridden on the command line
+	keyPreset := "default"
+	keyOverrides := map[string][]string{}
+	themeName := "default"
+	themeOverrides := map[string]map[string]string{}
+	entries, configErr := readConfigFile()
+	for _, entry := range entries {
+		name, element, found := strings.Cut(strings.TrimPrefix(entry.key, "theme."), ".")
+		if entry.key == "keys" {
+			keyPreset = entry.value
+		} else if strings.HasPrefix(entry.key, "bind.") {
+			keyOverrides[strings.TrimPrefix(entry.key, "bind.")] = splitList(entry.value)
+		} else if entry.key == "theme" {
+			themeName = entry.value
+		} else if strings.HasPrefix(entry.key, "theme.") && found {
+			if themeOverrides[name] == nil {
+				themeOverrides[name] = map[string]string{}
+			}
+			themeOverrides[name][element] = entry.value
+		} else if configErr == nil {
+			configErr = fmt.Errorf("Invalid configuration %s in line %d of %s", entry.key, entry.line, configPath())
+		}
+	}
+
+	if slices.Contains(os.Args[1:], "--help") {
+		// The help is also available with a broken configuration
+		keymap, err := NewKeymap(keyPreset, keyOverrides)
+		if configErr != nil || err != nil {
+			keymap, _ = NewKeymap("default", nil)
+		}
+		config.keymap = keymap
+		PrintHelp()
+		os.Exit(0)
+	}
+
+	if configErr != nil {
+		fmt.Fprintln(os.Stderr, configErr)
+		os.Exit(1)
+	}
+
 	if len(os.Args) > 1 {
 		inputPattern := ""
+		excludePatterns := []string{}
+		keywordSortMode := ""
 		remainingArgs := []string{}
-		for _, arg := range os.Args[1:] {
+		args := os.Args[1:]
+		for i := 0; i < len(args); i++ {
 			// Read switches in any order
+			arg := args[i]
+			if len(remainingArgs) > 0 && !strings.HasPrefix(arg, "--") {
+				// Short options like -c after PATTERN belong to COMMAND
+				remainingArgs = append(remainingArgs, arg)
+				continue
+			}
 			switch arg {
-			case "--help":
-				PrintHelp()
-				os.Exit(0)
 			case "--filter":
 				config.filter = true
+				config.invertFilter = false
+			case "--invert-filter":
+				config.invertFilter = true
+				config.filter = false
+			case "--exclude":
+				excludePatterns = append(excludePatterns, optionValue(args, &i))
 			case "--sort":
 				config.sort = 1
 			case "--sort-rev":
 				config.sort = -1
+			case "--unique":
+				config.unique = "match"
+			case "--unique-line":
+				config.unique = "line"
+			case "--group":
+				config.group = true
+			case "--columns":
+				config.table = tableColumns
+				config.jsonl = false
+			case "--csv":
+				config.table = tableCsv
+				config.jsonl = false
+			case "--tsv":
+				config.table = tableTsv
+				config.jsonl = false
+			case "--jsonl":
+				config.jsonl = true
+				config.table = ""
+			case "--display":
+				config.display = optionValue(args, &i)
+				config.jsonl = true
+				config.table = ""
+			case "--match-field":
+				config.matchField = optionValue(args, &i)
+				if !strings.HasPrefix(config.matchField, ".") {
+					fmt.Fprintln(os.Stderr, "Invalid field " + config.matchField + ", use a path like .id")
+					os.Exit(1)
+				}
+				config.jsonl = true
+				config.table = ""
+				// The whole value of the field is matched
+				inputPattern = "^(?s).+$"
+			case "-0", "--null":
+				config.delimiter = "\x00"
+			case "--delimiter":
+				delimiter, err := strconv.Unquote("\"" + optionValue(args, &i) + "\"")
+				if err != nil || delimiter == "" {
+					fmt.Fprintln(os.Stderr, "Invalid delimiter")
+					os.Exit(1)
+				}
+				config.delimiter = delimiter
+			case "--multi-line":
+				config.multiLine = true
+			case "--keep-empty":
+				config.keepEmpty = true
+			case "-f", "--file":
+				config.files = append(config.files, optionValue(args, &i))
+				config.inputCommand = ""
+			case "-c", "--command":
+				config.inputCommand = optionValue(args, &i)
+				config.files = []string{}
+			case "--header":
+				header, err := strconv.Atoi(optionValue(args, &i))
+				if err != nil || header < 1 {
+					fmt.Fprintln(os.Stderr, "Invalid number of header lines")
+					os.Exit(1)
+				}
+				config.header = header
+			case "--column":
+				config.column = optionValue(args, &i)
+			case "--sort-mode":
+				config.sortMode = optionValue(args, &i)
+				if !slices.Contains(sortModes, config.sortMode) {
+					fmt.Fprintln(os.Stderr, "Invalid sort mode " + config.sortMode + ", use one of " + strings.Join(sortModes, ", "))
+					os.Exit(1)
+				}
 			case "--show-output":
 				config.showProgramOutput = true
+			case "--shell":
+				config.shell = true
+			case "--confirm":
+				config.confirm = true
+			case "--dry-run":
+				config.dryRun = true
 			case "--ignore-error":
 				config.ignoreProgramError = true
+				config.exitOnProgramError = false
+			case "--exit-on-error":
+				config.exitOnProgramError = true
+				config.ignoreProgramError = false
+			case "--theme":
+				themeName = optionValue(args, &i)
+			case "--keys":
+				keyPreset = optionValue(args, &i)
+			case "--record":
+				config.record = true
+			case "--mouse":
+				config.mouse = true
+			case "--wrap":
+				config.wrap = true
+			case "--line-numbers":
+				config.lineNumbers = true
+			case "--history":
+				config.history = true
+			case "--timeout":
+				timeout, err := time.ParseDuration(optionValue(args, &i))
+				if err != nil || timeout <= 0 {
+					fmt.Fprintln(os.Stderr, "Invalid timeout")
+					os.Exit(1)
+				}
+				config.timeout = timeout
 			case "--line":
 				inputPattern = "^.*$"
 			case "--git-commit-hash":
 				inputPattern = "\\b[0-9a-f]{7,40}\\b"
 			case "--time":
 				inputPattern = "(?:0?[0-9]|1[0-9]|2[0-3]):[0-5][0-9](?::[0-5][0-9])?"
+				keywordSortMode = sortModeTime
 			case "--filename":
 				inputPattern = "[^\\s:]+"
+				keywordSortMode = sortModeNatural
 				config.patternFunc = func(p string) bool {
 					stat, err := os.Stat(p)
 					return err == nil && !stat.IsDir()
@@ -71,6 +289,7 @@ func NewConfig() *Config {
 				config.patternFuncInfo = "valid file"
 			case "--filename-lineno":
 				inputPattern = "[^\\s:]+:[1-9][0-9]*"
+				keywordSortMode = sortModeNatural
 				config.patternFunc = func(p string) bool {
 					splitted := strings.Split(p, ":")
 					if len(splitted) == 0 {
@@ -83,6 +302,7 @@ func NewConfig() *Config {
 				config.patternFuncInfo = "valid file"
 			case "--dirname":
 				inputPattern = "[^\\s:]+"
+				keywordSortMode = sortModeNatural
 				config.patternFunc = func(p string) bool {
 					stat, err := os.Stat(p)
 					return err == nil && stat.IsDir()
@@ -109,6 +329,13 @@ func NewConfig() *Config {
 			}
 		}
 
+		if config.sortMode == "" {
+			// Keywords compare their matches in a suitable way
+			config.sortMode = keywordSortMode
+		} else if config.sort == 0 {
+			config.sort = 1
+		}
+
 		offset := 0
 		if inputPattern == "" && len(remainingArgs) > 0 {
 			// No pattern has been set by a keyword, so assume the first argument is the pattern
@@ -116,9 +343,23 @@ func NewConfig() *Config {
 			offset++
 		}
 
+		// The dot also matches line breaks within records of --null or --delimiter
+		flags := ""
+		if config.delimiter != "" {
+			flags = "(?s)"
+		}
+		for _, exclude := range excludePatterns {
+			pattern, err := regexp.Compile(flags + exclude)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Invalid regular expression for --exclude")
+				os.Exit(1)
+			}
+			config.exclude = append(config.exclude, pattern)
+		}
+
 		if inputPattern != "" {
 			// Regex pattern
-			pattern, err := regexp.Compile(inputPattern)
+			pattern, err := regexp.Compile(flags + inputPattern)
 			if err != nil {
 				fmt.Fprintln(os.Stderr, "Invalid regular expression")
 				os.Exit(1)
@@ -126,17 +367,46 @@ func NewConfig() *Config {
 			config.pattern = pattern
 		}
 
-		if len(remainingArgs) > offset {
+		if config.shell {
+			// The whole command is a single string for the shell
+			config.program = strings.Join(remainingArgs[offset:], " ")
+		} else if len(remainingArgs) > offset {
 			// Program name
 			config.program = remainingArgs[offset]
 		}
 
-		if len(remainingArgs) > offset + 1 {
+		if !config.shell && len(remainingArgs) > offset + 1 {
 			// Program arguments
 			config.programArgs = remainingArgs[offset+1:]
 		}
 	}
 
+	keymap, err := NewKeymap(keyPreset, keyOverrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config.keymap = keymap
+
+	theme, err = NewTheme(themeName, themeOverrides, config.noColor)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if config.history {
+		// Execute the selected command of the history again
+		config.pattern = reHistoryCommand
+		config.patternFunc = nil
+		config.patternFuncInfo = "previous command"
+		config.program = os.Getenv("SHELL")
+		if config.program == "" {
+			config.program = "/bin/sh"
+		}
+		config.programArgs = []string{"-c", "{}"}
+		config.shell = false
+	}
+
 	if os.Getenv("LISST_TEST") != "" {
 		config.test = true
 	}
@@ -144,10 +414,76 @@ func NewConfig() *Config {
 	return config
 }
 
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "lisst", "config")
+}
+
+func readConfigFile() ([]configEntry, error) {
+	entries := []configEntry{}
+
+	file, err := os.Open(configPath())
+	if err != nil {
+		// The configuration file is optional
+		return entries, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		// Lines are given as key = value
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			return entries, fmt.Errorf("Invalid configuration in line %d of %s", line, configPath())
+		}
+		entries = append(entries, configEntry{strings.TrimSpace(key), strings.TrimSpace(value), line})
+	}
+	return entries, nil
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) != "" {
+			list = append(list, strings.TrimSpace(item))
+		}
+	}
+	return list
+}
+
+func optionValue(args []string, i *int) string {
+	// The value of an option is given as the next argument
+	if *i + 1 >= len(args) {
+		fmt.Fprintln(os.Stderr, "Missing value for command-line option " + args[*i])
+		os.Exit(1)
+	}
+	*i++
+	return args[*i]
+}
+
 func printCompletion(line string, current string) {
 	if strings.HasPrefix(current, "--") {
-		printCompletionOption(line, current, []string{"--help", "--filter", "--show-output", "--ignore-error"})
+		printCompletionOption(line, current, []string{"--help", "--show-output", "--timeout", "--shell", "--confirm", "--dry-run", "--record", "--history", "--mouse", "--wrap", "--line-numbers", "--keys", "--theme"})
+		printExclusiveCompletionOption(line, current, []string{"--ignore-error", "--exit-on-error"})
+		printExclusiveCompletionOption(line, current, []string{"--filter", "--invert-filter"})
 		printExclusiveCompletionOption(line, current, []string{"--sort", "--sort-rev"})
+		printCompletionOption(line, current, []string{"--sort-mode", "--group", "--exclude"})
+		printExclusiveCompletionOption(line, current, []string{"--unique", "--unique-line"})
+		printExclusiveCompletionOption(line, current, []string{"--columns", "--csv", "--tsv", "--jsonl"})
+		printCompletionOption(line, current, []string{"--header", "--column", "--display", "--match-field", "--multi-line", "--keep-empty", "--file", "--command"})
+		printExclusiveCompletionOption(line, current, []string{"--null", "--delimiter"})
 		printExclusiveCompletionOption(line, current, []string{"--line", "--git-commit-hash", "--filename", "--filename-lineno", "--dirname"})
 	} else {
 		hasPattern := false
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const historyFile = "history"
+const historyTimeFormat = "2006-01-02 15:04:05"
+
+// Matches the command in a line created by ReadHistory
+var reHistoryCommand = regexp.MustCompile("\\t(.+)$")
+
+// One executed command as stored in the history file
+type HistoryEntry struct {
+	Session time.Time `json:"session"`
+	Directory string `json:"directory"`
+	Pattern string `json:"pattern"`
+	Command string `json:"command"`
+	Time time.Time `json:"time"`
+	Match string `json:"match"`
+	Executed string `json:"executed"`
+	ExitStatus string `json:"exit_status"`
+}
+
+// Start of this invocation to group the history entries
+var sessionStart = time.Now()
+
+func historyPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "lisst", historyFile)
+}
+
+func RecordHistory(match string, placeholders Placeholders, result *Result) {
+	path := historyPath()
+	if path == "" {
+		return
+	}
+
+	pattern := ""
+	if config.pattern != nil {
+		pattern = config.pattern.String()
+	}
+	directory, _ := os.Getwd()
+
+	entry := HistoryEntry{
+		Session: sessionStart,
+		Directory: directory,
+		Pattern: pattern,
+		Command: strings.TrimSpace(config.program + " " + strings.Join(config.programArgs, " ")),
+		Time: result.start,
+		Match: match,
+		Executed: PrintShellCommand(match, placeholders),
+		ExitStatus: result.exitStatus,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	// Errors are ignored to not interrupt the work in the list
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.Write(append(data, '\n'))
+}
+
+func ReadHistory() ([]string, error) {
+	file, err := os.Open(historyPath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []HistoryEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024 * 1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	directory, _ := os.Getwd()
+	return formatHistory(entries, directory), nil
+}
+
+func formatHistory(entries []HistoryEntry, directory string) []string {
+	// Group the entries by session
+	sessions := [][]HistoryEntry{}
+	for _, entry := range entries {
+		last := len(sessions) - 1
+		if last >= 0 && sessions[last][0].Session.Equal(entry.Session) {
+			sessions[last] = append(sessions[last], entry)
+		} else {
+			sessions = append(sessions, []HistoryEntry{entry})
+		}
+	}
+
+	// Show the most recent session first
+	lines := []string{}
+	for i := len(sessions) - 1; i >= 0; i-- {
+		first := sessions[i][0]
+		lines = append(lines, fmt.Sprintf("%s  %s  %s  %s", first.Session.Format(historyTimeFormat), first.Directory, first.Pattern, first.Command))
+
+		for _, entry := range sessions[i] {
+			executed := entry.Executed
+			if entry.Directory != directory {
+				// Run the command where it has been run before
+				executed = "cd " + shellQuote(entry.Directory) + " && " + executed
+			}
+			lines = append(lines, fmt.Sprintf("    %s  %-10s\t%s", entry.Time.Format(time.TimeOnly), FormatExitStatus(entry.ExitStatus), executed))
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatHistory(t *testing.T) {
+	session1 := time.Date(2026, 1, 2, 10, 0, 0, 0, time.Local)
+	session2 := time.Date(2026, 1, 3, 10, 0, 0, 0, time.Local)
+
+	entries := []HistoryEntry{
+		{Session: session1, Directory: "/repo", Pattern: "[0-9a-f]{7}", Command: "git show", Time: session1.Add(time.Minute),
+			Match: "abcdef1", Executed: "git show abcdef1", ExitStatus: "0"},
+		{Session: session1, Directory: "/repo", Pattern: "[0-9a-f]{7}", Command: "git show", Time: session1.Add(2 * time.Minute),
+			Match: "1234567", Executed: "git show 1234567", ExitStatus: "128"},
+		{Session: session2, Directory: "/other dir", Pattern: "^.*$", Command: "rm", Time: session2.Add(time.Second),
+			Match: "file", Executed: "rm file", ExitStatus: "0"},
+	}
+
+	lines := formatHistory(entries, "/repo")
+	expected := []string{
+		"2026-01-03 10:00:00  /other dir  ^.*$  rm",
+		"    10:00:01  Exit=0    \tcd '/other dir' && rm file",
+		"2026-01-02 10:00:00  /repo  [0-9a-f]{7}  git show",
+		"    10:01:00  Exit=0    \tgit show abcdef1",
+		"    10:02:00  Exit=128  \tgit show 1234567",
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatal("Incorrect number of history lines")
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Error("Incorrect history line", lines[i])
+		}
+	}
+
+	if reHistoryCommand.FindStringSubmatch(lines[4])[1] != "git show 1234567" {
+		t.Error("Incorrect command matched in history line")
+	}
+}
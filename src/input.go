@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Error which stopped reading the input early, while the lines read so far are shown
+var inputError error
+
+// File of -f starting after the given number of input lines
+type inputSource struct {
+	name string
+	start int
+}
+
+var inputSources = []inputSource{}
+
+// Reads the files of -f or the output of the command of -c, which is stopped when the context is done.
+// The files are returned as sources to be set in inputSources together with the lines
+func readSources(ctx context.Context) ([]string, []inputSource, error) {
+	if config.inputCommand != "" {
+		input, err := readCommand(ctx, config.inputCommand)
+		return input, []inputSource{}, err
+	}
+
+	input := []string{}
+	sources := []inputSource{}
+	for _, name := range config.files {
+		if err := ctx.Err(); err != nil {
+			return input, sources, err
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return input, sources, err
+		}
+		sources = append(sources, inputSource{name, len(input)})
+		lines, err := readInput(file)
+		file.Close()
+		input = append(input, lines...)
+		if err != nil {
+			return input, sources, err
+		}
+	}
+	return input, sources, nil
+}
+
+// Runs the command of -c with $SHELL and reads its output
+func readCommand(ctx context.Context, command string) ([]string, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	cmd := exec.CommandContext(ctx, shell, "-c", command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	waitErr := runProcessGroup(cmd)
+	input, err := readInput(&stdout)
+	if ctx.Err() != nil {
+		return input, ctx.Err()
+	} else if err == nil && waitErr != nil {
+		err = waitErr
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+	}
+	return input, err
+}
+
+// Returns the file of -f of the input line with the given number and the number of lines before it
+func sourceOf(number int) (string, int) {
+	name, offset := "", 0
+	for _, source := range inputSources {
+		if source.start < number {
+			name, offset = source.name, source.start
+		}
+	}
+	return name, offset
+}
+
+// Returns whether there is no line besides blank lines
+func isEmpty(input []string) bool {
+	return !slices.ContainsFunc(input, func(line string) bool { return strings.TrimSpace(line) != "" })
+}
+
+// Reads all non-empty lines or records of --null or --delimiter, which are returned also on errors.
+// Blank lines are kept with --keep-empty
+func readInput(reader io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(reader)
+	// Lines are not limited in length, e.g. minified JSON
+	scanner.Buffer(make([]byte, 0, 64 * 1024), math.MaxInt)
+	if config.delimiter != "" {
+		scanner.Split(scanRecords)
+	}
+
+	input := []string{}
+	for scanner.Scan() {
+		// Read input line by line or record by record
+		line := scanner.Text()
+		if config.delimiter != "" && config.delimiter != "\x00" {
+			// Records of --delimiter are usually surrounded by line breaks, unlike file names of --null
+			line = strings.Trim(line, "\n")
+		}
+		if config.keepEmpty || strings.TrimSpace(line) != "" {
+			input = append(input, line)
+		}
+	}
+	return input, scanner.Err()
+}
+
+// Splits the input at the delimiter of --null or --delimiter
+func scanRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, []byte(config.delimiter)); i >= 0 {
+		return i + len(config.delimiter), data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reader which fails after returning its data
+type failingReader struct {
+	reader io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("broken pipe")
+	}
+	return n, err
+}
+
+func TestReadInput(t *testing.T) {
+	config = &Config{}
+	long := strings.Repeat("x", 1024 * 1024)
+	input, err := readInput(strings.NewReader("a\n\n" + long + "\nb"))
+	if err != nil || len(input) != 3 || input[1] != long || input[2] != "b" {
+		t.Error("Incorrect lines", len(input), err)
+	}
+
+	input, err = readInput(&failingReader{strings.NewReader("a\nb\n")})
+	if err == nil || len(input) != 2 {
+		t.Error("Lines before the error not kept", input, err)
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	config = &Config{}
+	config.delimiter = "\x00"
+	input, _ := readInput(strings.NewReader("a\nb\x00\x00\nc\n"))
+	if len(input) != 2 || input[0] != "a\nb" || input[1] != "\nc\n" {
+		t.Error("Incorrect records", input)
+	}
+
+	config.delimiter = "---"
+	input, _ = readInput(strings.NewReader("a\n---\nb\n"))
+	if len(input) != 2 || input[0] != "a" || input[1] != "b" {
+		t.Error("Incorrect records of delimiter", input)
+	}
+}
+
+func TestReadSources(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a"), []byte("a1\na2\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b"), []byte("b1\n"), 0644)
+	config = &Config{}
+	config.files = []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	input, sources, err := readSources(context.Background())
+	if err != nil || len(input) != 3 || len(sources) != 2 {
+		t.Error("Incorrect lines of files", input, err)
+	}
+	if len(inputSources) != 0 {
+		t.Error("Sources set while reading")
+	}
+	inputSources = sources
+
+	name, offset := sourceOf(3)
+	if name != config.files[1] || offset != 2 {
+		t.Error("Incorrect file of line", name, offset)
+	}
+	name, offset = sourceOf(2)
+	if name != config.files[0] || offset != 0 {
+		t.Error("Incorrect file of line", name, offset)
+	}
+	inputSources = []inputSource{}
+
+	// No more files are read when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	input, _, err = readSources(ctx)
+	if !errors.Is(err, context.Canceled) || len(input) != 0 {
+		t.Error("Files read after cancelling", input, err)
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	config = &Config{}
+	input, err := readCommand(context.Background(), "echo a; echo b")
+	if err != nil || len(input) != 2 || input[1] != "b" {
+		t.Error("Incorrect output of command", input, err)
+	}
+
+	input, err = readCommand(context.Background(), "echo a; echo failed >&2; exit 2")
+	if err == nil || !strings.Contains(err.Error(), "failed") || len(input) != 1 {
+		t.Error("Error of command not detected", input, err)
+	}
+
+	// A hanging command is stopped when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = readCommand(ctx, "sleep 10")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) >= killDelay {
+		t.Error("Hanging command not stopped", err, time.Since(start))
+	}
+}
@@ -1,69 +1,274 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
 )
 
 const tabSize = 4
+// Lines are cut in the display after this number of columns or the end of the match
+const maxDisplayWidth = 4096
+// Symbol for line breaks within records of --null or --delimiter without --multi-line
+const lineBreak = "␤"
 var reAnsiColorCodes = regexp.MustCompile("\\x1B\\[(([0-9]{1,2})?(;)?([0-9]{1,2})?)?[m,K,H,f,J]")
 var reColorTag = regexp.MustCompile("\\[[a-z-]+:[a-z:-]*\\]")
 
 type ItemList struct {
 	items []Item
+	// Lines of --header and the aligned names of the columns of a table pinned above the list
+	pinned []string
+	order string
+	grouped bool
+	// Lines hidden by the current view
+	hidden []Item
+	view string
+}
+
+const (
+	viewAll = "all lines"
+	viewMatching = "matching lines"
+	viewNonMatching = "non-matching lines"
+)
+
+// Views of the lines in the order they are cycled through
+var views = []string{viewAll, viewMatching, viewNonMatching}
+
+const (
+	sortOriginal = "input order"
+	sortAscending = "match ascending"
+	sortDescending = "match descending"
+	sortNumeric = "numeric match"
+	sortNatural = "natural match"
+	sortLine = "line text"
+)
+
+// Orders of the lines in the order they are cycled through
+var sortOrders = []string{sortOriginal, sortAscending, sortDescending, sortNumeric, sortNatural, sortLine}
+
+var reNumber = regexp.MustCompile("-?[0-9]+(\\.[0-9]+)?")
+
+// Modes of comparing matches when sorting by match
+const (
+	sortModeNatural = "natural"
+	sortModeVersion = "version"
+	sortModeHumanSize = "human-size"
+	sortModeTime = "time"
+	sortModeDate = "date"
+)
+
+var sortModes = []string{sortModeNatural, sortModeVersion, sortModeHumanSize, sortModeTime, sortModeDate}
+
+var reHumanSize = regexp.MustCompile("^(?i)([0-9]+(?:\\.[0-9]+)?)\\s*([kmgtpe]?)(?:i?b)?$")
+var reTime = regexp.MustCompile("^(?i)([0-9]{1,2}):([0-9]{2})(?::([0-9]{2}(?:\\.[0-9]+)?))?\\s*([ap]m)?$")
+
+// Layouts of dates in the order they are tried
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"01/02/2006",
+	"2 Jan 2006",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"Jan _2 15:04:05",
+	time.RFC1123,
+	time.RFC1123Z,
+	time.UnixDate,
+	time.ANSIC,
 }
 
 type Item struct {
+	// Number of the line in the input starting at 1
+	number int
+	// Input line including ANSI color codes
+	line string
 	original string
 	display string
 	match string
+	groups []string
+	// Columns of the match in the display without the marker
+	matchStart int
+	matchEnd int
+	exitStatus string
+	executed time.Time
+	// Later lines with the same match or text collapsed into this one
+	duplicates []Item
+	expanded bool
+	// Header of a group of lines with the same match and the lines nested below
+	header bool
+	children []Item
+	child bool
+	// Fields of a table with the names of the columns and the position of --column in the original line
+	fields []string
+	fieldNames []string
+	column bool
+	columnStart int
+	columnEnd int
+	// Fields of a JSON line by their path like .a.b, and lines which are no valid JSON
+	values map[string]string
+	invalid bool
+	// Blank line of --keep-empty, which cannot be selected
+	separator bool
+	// File of -f and the number of input lines before it
+	source string
+	sourceOffset int
 }
 
 func NewItemList(input []string) *ItemList {
+	pinned, items := splitInput(input)
+	list := newItemList(pinned)
+	for _, item := range items {
+		if !item.separator {
+			item.process(item.line)
+		}
+		list.items = append(list.items, item)
+	}
+	return list
+}
+
+// Creates an empty list with the given lines of --header pinned above it
+func newItemList(pinned []string) *ItemList {
 	list := &ItemList {
-		items: make([]Item, len(input)),
+		items: []Item{},
+		order: sortOriginal,
+		view: viewAll,
+	}
+	for _, line := range pinned {
+		list.pinned = append(list.pinned, pinnedLine(line))
 	}
+	return list
+}
 
+// Splits the input into the lines of --header and the items of the other lines, except those of --exclude.
+// The items only have their line and its number, where blank lines of --keep-empty are separators which are never matched
+func splitInput(input []string) ([]string, []Item) {
+	pinned := []string{}
+	items := []Item{}
 	for i, line := range input {
-		list.items[i].process(line)
+		if i < config.header {
+			pinned = append(pinned, line)
+			continue
+		}
+		if isExcluded(line) {
+			continue
+		}
+		item := Item{
+			number: i + 1,
+			line: line,
+			separator: strings.TrimSpace(line) == "",
+		}
+		item.source, item.sourceOffset = sourceOf(item.number)
+		items = append(items, item)
 	}
+	return pinned, items
+}
 
-	return list
+// Returns the line of --header as it is displayed, which is never matched
+func pinnedLine(line string) string {
+	if config.noColor {
+		line = tview.Escape(escapeBinary(reAnsiColorCodes.ReplaceAllString(line, "")))
+	} else {
+		line = strings.ReplaceAll(tview.TranslateANSI(tview.Escape(escapeBinary(line))), "[-:-:-]", "[-:-:]")
+	}
+	return strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabSize))
 }
 
 func (item *Item) process(line string) {
+	item.line = line
+	item.highlight(-1)
+}
+
+// Highlights the first valid match or the one covering the given byte position of the original line
+func (item *Item) highlight(position int) {
+	line := item.line
+	item.match = ""
+	item.groups = nil
+	item.matchStart = 0
+	item.matchEnd = 0
+
 	// Remove all ANSI color codes
 	item.original = reAnsiColorCodes.ReplaceAllString(line, "")
 
 	// Replace all [foobar] with [foobar[] to not confuse the color display in the list
 	// see https://github.com/rivo/tview/blob/master/doc.go
-	item.display = tview.Escape(line)
+	// Binary data is only escaped in the display, while the match is passed to COMMAND as it is
+	if config.noColor {
+		item.display = tview.Escape(escapeBinary(item.original))
+	} else {
+		item.display = tview.Escape(escapeBinary(line))
 
-	// Replace all ANSI color codes with the corresponding color tags
-	item.display = strings.ReplaceAll(tview.TranslateANSI(item.display), "[-:-:-]", "[-:-:]")
+		// Replace all ANSI color codes with the corresponding color tags
+		item.display = strings.ReplaceAll(tview.TranslateANSI(item.display), "[-:-:-]", "[-:-:]")
+	}
 
-	if config.pattern != nil {
-		tokens := config.pattern.FindAllStringSubmatch(item.original, -1)
-		if tokens != nil {
-			for _, matches := range tokens {
-				// Highlight the first match only
-				if item.highlightFirstMatch(matches) {
-					break
+	if item.invalid {
+		// Lines which could not be parsed have no match
+		item.display = theme.Apply(theme.invalid, tview.Escape(escapeBinary(item.original)))
+	} else if config.pattern != nil {
+		for _, token := range item.tokens() {
+			if position >= 0 && (position < token[0] || position >= token[1]) {
+				continue
+			}
+			// Highlight the first match only
+			if item.highlightFirstMatch(submatches(item.original, token), token[0]) {
+				index := 0
+				if len(token) > 2 && token[2] >= 0 {
+					index = 1
 				}
+				item.matchStart = displayWidth(item.original[:token[2 * index]])
+				item.matchEnd = item.matchStart + displayWidth(item.match)
+				break
 			}
 		}
 	}
 
 	// Replace tab characters
 	item.display = strings.ReplaceAll(item.display, "\t", strings.Repeat(" ", tabSize))
+	if !config.multiLine {
+		// Show line breaks within records in a single row
+		item.display = strings.ReplaceAll(item.display, "\n", lineBreak)
+	}
+	if displayWidth(item.original) > maxDisplayWidth {
+		item.display = truncateDisplay(item.display, max(maxDisplayWidth, item.matchEnd))
+	}
 }
 
-func (item *Item) highlightFirstMatch(matches []string) bool {
+// Returns the positions of all matches and submatches in the original line
+func (item *Item) tokens() [][]int {
+	if !item.column {
+		return config.pattern.FindAllStringSubmatchIndex(item.original, -1)
+	}
+
+	// Match the field of --column only
+	tokens := config.pattern.FindAllStringSubmatchIndex(item.original[item.columnStart:item.columnEnd], -1)
+	for _, token := range tokens {
+		for i := range token {
+			if token[i] >= 0 {
+				token[i] += item.columnStart
+			}
+		}
+	}
+	return tokens
+}
+
+func (item *Item) highlightFirstMatch(matches []string, position int) bool {
 	// If there is any submatch, highlight the first submatch, otherwise highlight the entire match
 	index := 0
 	if len(matches) > 1 {
@@ -74,96 +279,774 @@ func (item *Item) highlightFirstMatch(matches []string) bool {
 	// Check the match using the pattern function and highlight it if the result is true
 	if config.patternFunc == nil || config.patternFunc(match) {
 		item.match = match
-		highlighted := strings.Replace(matches[0], item.match, "[::-][::r]" + item.match + "[::-]", 1)
-		if strings.Contains(item.display, matches[0]) {
-			item.display = replaceFirst(item.display, matches[0], highlighted)
+		item.groups = matches[1:]
+		// The display shows binary data escaped
+		before, after, _ := strings.Cut(matches[0], item.match)
+		before, after, shown := escapeBinary(before), escapeBinary(after), escapeBinary(matches[0])
+		if strings.Contains(item.display, shown) {
+			// Restore the colors of the input after the highlight
+			i := indexFrom(item.display, shown, len(escapeBinary(item.original[:position])))
+			active := activeStyle(item.display[:i])
+			highlighted := theme.Group(before, active) + theme.Match(escapeBinary(item.match), active) + theme.Group(after, active)
+			item.display = item.display[:i] + highlighted + item.display[i + len(shown):]
 		} else {
 			// Special case where the color ranges intersect
-			item.display = mergeStrings(item.display, strings.Replace(item.original, matches[0], highlighted, 1))
+			highlighted := theme.Group(before, "") + theme.Match(escapeBinary(item.match), "") + theme.Group(after, "")
+			item.display = mergeStrings(item.display, strings.Replace(escapeBinary(item.original), shown, highlighted, 1))
 		}
 		return true
 	}
 	return false
 }
 
+// Selects the match at the given column of the displayed line, e.g. on a mouse click
+func (item *Item) SelectMatchAt(column int) bool {
+	if config.pattern == nil || item.line == "" {
+		return false
+	}
+
+	// Find the position in the original line, where tabs are expanded and binary data is escaped in the display
+	position := -1
+	width := 0
+	for i := 0; i < len(item.original); {
+		_, size := utf8.DecodeRuneInString(item.original[i:])
+		width += displayWidth(item.original[i:i + size])
+		if width > column {
+			position = i
+			break
+		}
+		i += size
+	}
+	if position < 0 {
+		return false
+	}
+
+	for _, token := range item.tokens() {
+		if position < token[0] || position >= token[1] {
+			continue
+		}
+		matches := submatches(item.original, token)
+		index := 0
+		if len(matches) > 1 {
+			index = 1
+		}
+		if config.patternFunc == nil || config.patternFunc(matches[index]) {
+			item.highlight(position)
+			return true
+		}
+	}
+	return false
+}
+
 func (item *Item) HasMatch() bool {
 	return item.match != ""
 }
 
+func (item *Item) Executed() bool {
+	return item.exitStatus != ""
+}
+
+func (item *Item) Failed() bool {
+	return item.Executed() && item.exitStatus != "0"
+}
+
+func (item *Item) Unvisited() bool {
+	return item.HasMatch() && !item.Executed()
+}
+
+func (item *Item) Display(marked bool) string {
+	display := item.display
+	if item.header {
+		// Number of lines in the group
+		display += " " + theme.Apply(theme.number, fmt.Sprintf("×%d", len(item.children)))
+	} else if len(item.duplicates) > 0 {
+		// Number of collapsed lines behind the line
+		display += " " + theme.Apply(theme.number, fmt.Sprintf("×%d", len(item.duplicates) + 1))
+	}
+	if item.child {
+		display = "  " + display
+	}
+
+	// Marker in front of the line if the command has been executed
+	if !marked {
+		return display
+	} else if item.Failed() {
+		return theme.Apply(theme.failed, "✗") + " " + display
+	} else if item.Executed() {
+		return theme.Apply(theme.executed, "✓") + " " + display
+	}
+	return "  " + display
+}
+
 func (item *Item) PrintCommand() string {
-	return PrintCommand(item.match)
+	return PrintCommand(item.match, item.Placeholders())
 }
 
-func (item *Item) RunCommand() (string, string, string) {
-	return RunCommand(item.match)
+func (item *Item) RunCommand(index int) *Result {
+	result := RunCommand(item.match, item.Placeholders(), item.Environment(index))
+	item.exitStatus = result.exitStatus
+	item.executed = result.start
+	return result
+}
+
+func (item *Item) Placeholders() Placeholders {
+	if item.header {
+		// There is no single line for the match of a group, so {n} and the fields are kept as they are
+		return Placeholders{}
+	}
+	placeholders := Placeholders{}
+	// Fields of a table by number and by the name of their column
+	for i, field := range item.fields {
+		placeholders[strconv.Itoa(i + 1)] = field
+		if i < len(item.fieldNames) {
+			placeholders[item.fieldNames[i]] = field
+		}
+	}
+	for path, value := range item.values {
+		placeholders[path] = value
+	}
+	if item.source != "" {
+		placeholders["src"] = item.source
+	}
+	placeholders["n"] = strconv.Itoa(item.LineNumber())
+	return placeholders
+}
+
+// Returns the number of the line in the input or in its file of -f
+func (item *Item) LineNumber() int {
+	return item.number - item.sourceOffset
+}
+
+func (item *Item) Environment(index int) []string {
+	// Variables passed to the command in addition to the arguments
+	env := []string{
+		"LISST_MATCH=" + item.match,
+		"LISST_LINE=" + item.original,
+		"LISST_INDEX=" + strconv.Itoa(index + 1),
+	}
+	if !item.header {
+		// The header of a group has no line in the input
+		env = append(env, "LISST_NUMBER=" + strconv.Itoa(item.LineNumber()))
+	}
+	env = append(env, "LISST_SELECTED=" + item.match)
+	if config.pattern != nil {
+		env = append(env, "LISST_PATTERN=" + config.pattern.String())
+	}
+	if item.source != "" {
+		env = append(env, "LISST_SOURCE=" + item.source)
+	}
+	for i, field := range item.fields {
+		env = append(env, fmt.Sprintf("LISST_FIELD_%d=%s", i + 1, field))
+	}
+	for i, group := range item.groups {
+		env = append(env, fmt.Sprintf("LISST_GROUP_%d=%s", i + 1, group))
+	}
+	return env
 }
 
 func (list *ItemList) NumMatches() int {
 	count := 0
 	for _, item := range list.items {
-		if item.HasMatch() {
+		if item.header && !item.expanded {
+			// Lines of collapsed groups
+			count += len(item.children)
+		} else if item.HasMatch() && !item.header {
 			count++
 		}
 	}
 	return count
 }
 
-func (list *ItemList) Filter() error {
-	count := list.NumMatches()
+func (list *ItemList) NumExecuted() int {
+	count := 0
+	for _, item := range list.items {
+		if item.Executed() {
+			count++
+		}
+	}
+	return count
+}
 
-	if count == 0 {
-		return errors.New("Empty list")
+// Collapses all lines with the same match or, if byLine is set, with the same text into the first one
+func (list *ItemList) Unique(byLine bool) {
+	items := []Item{}
+	first := map[string]int{}
+	for _, item := range list.items {
+		if item.separator || !byLine && !item.HasMatch() {
+			items = append(items, item)
+			continue
+		}
+		key := item.original
+		if !byLine {
+			key = item.match
+		}
+
+		if i, ok := first[key]; ok {
+			items[i].duplicates = append(items[i].duplicates, item)
+		} else {
+			first[key] = len(items)
+			items = append(items, item)
+		}
+	}
+	list.items = items
+}
+
+// Shows or hides the lines collapsed into the line of the given index and returns whether there are any
+func (list *ItemList) Toggle(index int) bool {
+	item := &list.items[index]
+	if item.header {
+		list.toggleGroup(index)
+		return true
+	} else if len(item.duplicates) == 0 {
+		return false
+	}
+
+	if !item.expanded {
+		item.expanded = true
+		duplicates := slices.Clone(item.duplicates)
+		for i := range duplicates {
+			// Shown lines stay in the group of the line
+			duplicates[i].child = item.child
+		}
+		list.items = slices.Insert(list.items, index + 1, duplicates...)
+		return true
+	}
+
+	// Keep the state of the shown lines, e.g. whether COMMAND has been executed
+	item.expanded = false
+	duplicates := map[int]bool{}
+	for _, duplicate := range item.duplicates {
+		duplicates[duplicate.number] = true
+	}
+	for i, other := range list.items {
+		if duplicates[other.number] {
+			item.duplicates[slices.IndexFunc(item.duplicates, func(duplicate Item) bool {
+				return duplicate.number == other.number
+			})] = list.items[i]
+		}
 	}
+	list.items = slices.DeleteFunc(list.items, func(other Item) bool {
+		return duplicates[other.number]
+	})
+	return true
+}
 
-	items := make([]Item, count)
+// Shows or hides the lines of the group with the header at the given index
+func (list *ItemList) toggleGroup(index int) {
+	header := &list.items[index]
+	if !header.expanded {
+		header.expanded = true
+		list.items = slices.Insert(list.items, index + 1, header.children...)
+		return
+	}
 
-	count = 0
+	// Keep the shown lines as they are, e.g. with expanded duplicates or whether COMMAND has been executed
+	end := index + 1
+	for end < len(list.items) && list.items[end].child {
+		end++
+	}
+	header.expanded = false
+	header.children = slices.Clone(list.items[index + 1:end])
+	list.items = slices.Delete(list.items, index + 1, end)
+}
+
+// Nests all lines below a header for each distinct match, where lines without a match go last
+func (list *ItemList) Group(collapsed map[string]bool) {
+	headers := []Item{}
+	others := []Item{}
+	groups := map[string]int{}
 	for _, item := range list.items {
-		if item.HasMatch() {
-			items[count] = item
-			count++
+		if !item.HasMatch() {
+			others = append(others, item)
+			continue
+		}
+
+		i, ok := groups[item.match]
+		if !ok {
+			i = len(headers)
+			groups[item.match] = i
+			headers = append(headers, Item{
+				// Headers are told apart from the input lines by negative numbers, which do not change when sorting
+				number: -item.number,
+				original: item.match,
+				display: theme.Match(tview.Escape(escapeBinary(item.match)), ""),
+				match: item.match,
+				matchEnd: displayWidth(item.match),
+				header: true,
+			})
 		}
+		item.child = true
+		headers[i].number = max(headers[i].number, -item.number)
+		headers[i].children = append(headers[i].children, item)
 	}
 
+	items := []Item{}
+	for _, header := range headers {
+		header.expanded = !collapsed[header.match]
+		items = append(items, header)
+		if header.expanded {
+			items = append(items, header.children...)
+		}
+	}
+	list.items = append(items, others...)
+	list.grouped = true
+}
+
+// Removes the headers of the groups and returns the matches of the collapsed groups
+func (list *ItemList) ungroup() map[string]bool {
+	collapsed := map[string]bool{}
+	items := []Item{}
+	for _, item := range list.items {
+		if !item.header {
+			// Shown lines of expanded groups
+			items = append(items, item)
+		} else if !item.expanded {
+			collapsed[item.match] = true
+			items = append(items, item.children...)
+		}
+	}
+	for i := range items {
+		items[i].child = false
+	}
 	list.items = items
+	list.grouped = false
+	return collapsed
+}
+
+// Returns the index of the header of the group containing the line of the given index or -1
+func (list *ItemList) Header(index int) int {
+	for i := index; i >= 0; i-- {
+		if list.items[i].header {
+			return i
+		}
+	}
+	return -1
+}
+
+func (list *ItemList) Filter() error {
+	return list.SetView(viewMatching)
+}
+
+// Shows all lines, the lines with a match only or the lines without a match only
+func (list *ItemList) SetView(view string) error {
+	var collapsed map[string]bool
+	grouped := list.grouped
+	if grouped {
+		collapsed = list.ungroup()
+	}
+
+	items := []Item{}
+	hidden := []Item{}
+	for _, item := range slices.Concat(list.items, list.hidden) {
+		if view == viewAll || item.HasMatch() == (view == viewMatching) {
+			items = append(items, item)
+		} else {
+			hidden = append(hidden, item)
+		}
+	}
+
+	if len(items) > 0 {
+		list.items = items
+		list.hidden = hidden
+		list.view = view
+		list.SortBy(list.order)
+	}
+	if grouped {
+		list.Group(collapsed)
+	}
+
+	if len(items) == 0 {
+		return errors.New("Empty list")
+	}
 	return nil
 }
 
+// Returns the view following the current one, which is not empty
+func (list *ItemList) NextView() string {
+	index := slices.Index(views, list.view)
+	for i := 1; i < len(views); i++ {
+		view := views[(index + i) % len(views)]
+		for _, item := range slices.Concat(list.items, list.hidden) {
+			if view == viewAll || !item.header && item.HasMatch() == (view == viewMatching) {
+				return view
+			}
+		}
+	}
+	return list.view
+}
+
 func (list *ItemList) Sort(order int) {
+	list.order = sortAscending
+	if order < 0 {
+		list.order = sortDescending
+	}
+
 	sort.SliceStable(list.items, func(i int, j int) bool {
 		if list.items[i].HasMatch() && list.items[j].HasMatch() {
-			iVal, iErr := strconv.ParseFloat(list.items[i].match, 64)
-			jVal, jErr := strconv.ParseFloat(list.items[j].match, 64)
-			if iErr == nil && jErr == nil {
-				if order > 0 {
-					return iVal < jVal
-				} else {
-					return iVal > jVal
-				}
+			result := compareMatches(list.items[i].match, list.items[j].match)
+			if result == 0 {
+				// Keep equal matches in the order of the input
+				return list.items[i].number < list.items[j].number
+			}
+			if order > 0 {
+				return result < 0
 			} else {
-				if order > 0 {
-					return list.items[i].match < list.items[j].match
-				} else {
-					return list.items[i].match > list.items[j].match
-				}
+				return result > 0
 			}
 		} else if list.items[i].HasMatch() {
 			return true
 		} else if list.items[j].HasMatch() {
 			return false
 		}
-		return false
+		return list.items[i].number < list.items[j].number
 	})
 }
 
+func compareMatches(a string, b string) int {
+	switch config.sortMode {
+	case sortModeNatural:
+		return compareNatural(a, b)
+	case sortModeVersion:
+		return compareVersion(a, b)
+	case sortModeHumanSize:
+		return compareParsed(a, b, parseHumanSize)
+	case sortModeTime:
+		return compareParsed(a, b, parseTime)
+	case sortModeDate:
+		return compareParsed(a, b, parseDate)
+	}
+
+	// Compare numbers by value and everything else as text
+	return compareParsed(a, b, func(s string) (float64, bool) {
+		value, err := strconv.ParseFloat(s, 64)
+		return value, err == nil
+	})
+}
+
+// Compares the parsed values, where values which cannot be parsed go last and are compared as text
+func compareParsed(a string, b string, parse func(string) (float64, bool)) int {
+	aValue, aOk := parse(a)
+	bValue, bOk := parse(b)
+	if aOk && bOk {
+		return cmp.Compare(aValue, bValue)
+	} else if aOk {
+		return -1
+	} else if bOk {
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Compares versions like 1.9.0 < 1.10.0 and 2.0.0-rc1 < 2.0.0
+func compareVersion(a string, b string) int {
+	aRelease, aPre := splitVersion(a)
+	bRelease, bPre := splitVersion(b)
+
+	aFields := strings.Split(aRelease, ".")
+	bFields := strings.Split(bRelease, ".")
+	for i := 0; i < max(len(aFields), len(bFields)); i++ {
+		// Missing fields are zero, i.e. 1.2 equals 1.2.0
+		aField, bField := "0", "0"
+		if i < len(aFields) {
+			aField = aFields[i]
+		}
+		if i < len(bFields) {
+			bField = bFields[i]
+		}
+		if result := compareNatural(aField, bField); result != 0 {
+			return result
+		}
+	}
+
+	// A pre-release comes before the release
+	if aPre == "" || bPre == "" {
+		return len(bPre) - len(aPre)
+	}
+	return compareNatural(aPre, bPre)
+}
+
+func splitVersion(version string) (string, string) {
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	version, _, _ = strings.Cut(version, "+")
+	release, pre, _ := strings.Cut(version, "-")
+	return release, pre
+}
+
+// Parses sizes like 512, 2K, 1.5GiB or 10MB with a factor of 1024 per unit
+func parseHumanSize(s string) (float64, bool) {
+	matches := reHumanSize.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	if matches[2] != "" {
+		exponent := strings.Index("kmgtpe", strings.ToLower(matches[2])) + 1
+		value *= math.Pow(1024, float64(exponent))
+	}
+	return value, true
+}
+
+// Parses times of the day like 9:05, 10:00:30 or 1:15 pm to seconds
+func parseTime(s string) (float64, bool) {
+	matches := reTime.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, false
+	}
+	hours, _ := strconv.ParseFloat(matches[1], 64)
+	minutes, _ := strconv.ParseFloat(matches[2], 64)
+	seconds, _ := strconv.ParseFloat(matches[3], 64)
+	switch strings.ToLower(matches[4]) {
+	case "am":
+		hours = math.Mod(hours, 12)
+	case "pm":
+		hours = math.Mod(hours, 12) + 12
+	}
+	return hours * 3600 + minutes * 60 + seconds, true
+}
+
+// Parses dates in one of the common layouts to seconds since the epoch
+func parseDate(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, s)
+		if err == nil {
+			return float64(date.UnixNano()) / 1e9, true
+		}
+	}
+	return 0, false
+}
+
+func (list *ItemList) SortBy(order string) {
+	if list.grouped {
+		// Sort the lines and order the groups by their first line
+		collapsed := list.ungroup()
+		defer list.Group(collapsed)
+	}
+
+	switch order {
+	case sortAscending:
+		list.Sort(1)
+	case sortDescending:
+		list.Sort(-1)
+	case sortNumeric:
+		list.sortMatches(func(a string, b string) bool {
+			aNumber, aErr := strconv.ParseFloat(reNumber.FindString(a), 64)
+			bNumber, bErr := strconv.ParseFloat(reNumber.FindString(b), 64)
+			if aErr != nil || bErr != nil {
+				// Matches without a number go last
+				return aErr == nil && bErr != nil
+			}
+			return aNumber < bNumber
+		})
+	case sortNatural:
+		list.sortMatches(func(a string, b string) bool {
+			return compareNatural(a, b) < 0
+		})
+	case sortLine:
+		sort.SliceStable(list.items, func(i int, j int) bool {
+			return list.items[i].original < list.items[j].original
+		})
+	default:
+		order = sortOriginal
+		sort.SliceStable(list.items, func(i int, j int) bool {
+			return list.items[i].number < list.items[j].number
+		})
+	}
+	list.order = order
+}
+
+// Returns the order following the current one
+func (list *ItemList) NextOrder() string {
+	for i, order := range sortOrders {
+		if order == list.order {
+			return sortOrders[(i + 1) % len(sortOrders)]
+		}
+	}
+	return sortOrders[0]
+}
+
+// Sorts the lines by their matches, where lines without a match go last
+func (list *ItemList) sortMatches(less func(string, string) bool) {
+	sort.SliceStable(list.items, func(i int, j int) bool {
+		if list.items[i].HasMatch() && list.items[j].HasMatch() {
+			return less(list.items[i].match, list.items[j].match)
+		}
+		return list.items[i].HasMatch() && !list.items[j].HasMatch()
+	})
+}
+
+// Index of the item with the given input line number or -1
+func (list *ItemList) Find(number int) int {
+	for i, item := range list.items {
+		if item.number == number {
+			return i
+		}
+	}
+	return -1
+}
+
 func (list *ItemList) Get(index int) *Item {
 	return &list.items[index]
 }
 
-func (list *ItemList) Print() {
+// Returns the line of the given index with the markers of executed lines and the line numbers in front
+// Markers and line numbers in front of all lines, which are computed once for displaying the whole list
+type Gutter struct {
+	marked bool
+	numberWidth int
+}
+
+func (list *ItemList) Gutter() Gutter {
+	gutter := Gutter{marked: list.NumExecuted() > 0}
+	if config.lineNumbers {
+		gutter.numberWidth = list.numberWidth()
+	}
+	return gutter
+}
+
+func (list *ItemList) Display(index int, gutter Gutter) string {
+	item := &list.items[index]
+	display := item.Display(gutter.marked)
+	if config.lineNumbers && (item.header || item.separator) {
+		display = strings.Repeat(" ", gutter.numberWidth + 1) + display
+	} else if config.lineNumbers {
+		display = theme.Apply(theme.number, fmt.Sprintf("%*d", gutter.numberWidth, item.LineNumber())) + " " + display
+	}
+	return display
+}
+
+// Width of everything in front of the line of the given index
+func (list *ItemList) GutterWidth(index int, gutter Gutter) int {
+	width := 0
+	if list.items[index].child {
+		width += 2
+	}
+	if gutter.marked {
+		width += 2
+	}
+	if config.lineNumbers {
+		width += gutter.numberWidth + 1
+	}
+	return width
+}
+
+// Returns the pinned lines aligned with the lines of the list
+func (list *ItemList) DisplayPinned() []string {
+	gutter := list.Gutter()
+	width := 0
+	if gutter.marked {
+		width += 2
+	}
+	if config.lineNumbers {
+		width += gutter.numberWidth + 1
+	}
+	lines := []string{}
+	for _, line := range list.pinned {
+		lines = append(lines, strings.Repeat(" ", width) + theme.Apply(theme.header, line))
+	}
+	return lines
+}
+
+// Returns the index of the nearest line which is no separator, searching in the given direction first
+func (list *ItemList) Selectable(index int, direction int) int {
+	if direction == 0 {
+		direction = 1
+	}
+	for _, step := range []int{direction, -direction} {
+		for i := index; i >= 0 && i < len(list.items); i += step {
+			if !list.items[i].separator {
+				return i
+			}
+		}
+	}
+	return index
+}
+
+func (list *ItemList) numberWidth() int {
+	number := 0
 	for _, item := range list.items {
-		fmt.Println(item.display)
+		number = max(number, item.LineNumber())
+	}
+	return len(strconv.Itoa(number))
+}
+
+func (list *ItemList) Print() {
+	for _, line := range list.DisplayPinned() {
+		fmt.Println(line)
+	}
+	gutter := list.Gutter()
+	for i := range list.items {
+		fmt.Println(list.Display(i, gutter))
+	}
+}
+
+// Returns whether the line matches any of the regular expressions of --exclude
+func isExcluded(line string) bool {
+	if len(config.exclude) == 0 {
+		return false
+	}
+	line = reAnsiColorCodes.ReplaceAllString(line, "")
+	for _, exclude := range config.exclude {
+		if exclude.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Width of the text in terminal cells, where tabs are expanded, wide characters take two cells, line breaks one and
+// binary data is escaped
+func displayWidth(s string) int {
+	return uniseg.StringWidth(escapeBinary(s)) + strings.Count(s, "\t") * tabSize + strings.Count(s, "\n")
+}
+
+// Compares strings like a human, where numbers within the strings are compared by value, e.g. file2 < file10
+func compareNatural(a string, b string) int {
+	for a != "" && b != "" {
+		aDigits := leadingDigits(a)
+		bDigits := leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aValue := strings.TrimLeft(aDigits, "0")
+			bValue := strings.TrimLeft(bDigits, "0")
+			if len(aValue) != len(bValue) {
+				return len(aValue) - len(bValue)
+			}
+			if aValue != bValue {
+				return strings.Compare(aValue, bValue)
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+
+		aRune, aSize := utf8.DecodeRuneInString(a)
+		bRune, bSize := utf8.DecodeRuneInString(b)
+		if aRune != bRune {
+			return int(aRune) - int(bRune)
+		}
+		a, b = a[aSize:], b[bSize:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+func submatches(s string, token []int) []string {
+	matches := make([]string, len(token) / 2)
+	for i := range matches {
+		if token[2 * i] >= 0 {
+			matches[i] = s[token[2 * i]:token[2 * i + 1]]
+		}
 	}
+	return matches
 }
 
 func mergeStrings(string1 string, string2 string) string {
@@ -206,17 +1089,64 @@ func mergeStrings(string1 string, string2 string) string {
 	return string(result)
 }
 
-func replaceFirst(s string, old string, new string) string {
-	var b strings.Builder
-	b.Grow(len(s) + len(new) - len(old))
+// Returns the first occurrence of old starting at the given position, which is never behind the position in the display
+func indexFrom(s string, old string, position int) int {
+	masked := reColorTag.ReplaceAllStringFunc(s, maskString)
+	i := -1
+	if position < len(masked) {
+		i = strings.Index(masked[position:], old)
+	}
+	if i >= 0 {
+		i += position
+	} else {
+		i = strings.Index(masked, old)
+	}
+	return i
+}
 
-	i := strings.Index(reColorTag.ReplaceAllStringFunc(s, maskString), old)
-	b.WriteString(s[:i])
-	b.WriteString(new)
-	b.WriteString(s[i + len(old):])
+// Replaces invalid UTF-8 and control characters except tabs, line breaks and color codes with escaped bytes like \x00
+func escapeBinary(s string) string {
+	if utf8.ValidString(s) && strings.IndexFunc(s, isBinary) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 || isBinary(r) {
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		} else {
+			b.WriteRune(r)
+		}
+		i += size
+	}
 	return b.String()
 }
 
+func isBinary(r rune) bool {
+	return (r < 0x20 || r == 0x7f) && r != '\t' && r != '\n' && r != '\x1b'
+}
+
+// Cuts the displayed line after the given number of columns, where color tags take no columns
+func truncateDisplay(display string, width int) string {
+	tags := reStyleTag.FindAllStringIndex(display, -1)
+	columns := 0
+	for i := 0; i < len(display); {
+		if len(tags) > 0 && tags[0][0] == i {
+			i = tags[0][1]
+			tags = tags[1:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(display[i:])
+		columns += displayWidth(string(r))
+		if columns > width {
+			return display[:i] + "[-:-:-]…"
+		}
+		i += size
+	}
+	return display
+}
+
 func maskString(s string) string {
 	return strings.Repeat(" ", len(s))
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -176,6 +179,130 @@ func TestFilter(t *testing.T) {
 	}
 }
 
+func TestSetView(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9]")
+	list := NewItemList([]string{"a1", "b", "c2", "d"})
+
+	if list.SetView(viewNonMatching) != nil || len(list.items) != 2 || list.items[1].number != 4 || len(list.hidden) != 2 {
+		t.Error("Incorrect non-matching lines")
+	}
+	if list.NextView() != viewAll {
+		t.Error("Incorrect next view")
+	}
+	list.SortBy(sortLine)
+	if list.SetView(viewAll) != nil || len(list.items) != 4 || list.items[0].number != 1 || list.items[3].number != 4 {
+		t.Error("Incorrect sorted lines after showing all lines")
+	}
+
+	list = NewItemList([]string{"a", "b"})
+	if list.SetView(viewMatching) == nil || len(list.items) != 2 || list.view != viewAll || list.NextView() != viewNonMatching {
+		t.Error("Incorrect empty view")
+	}
+}
+
+func TestExclude(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9a-f]{7}")
+	config.exclude = []*regexp.Regexp{regexp.MustCompile("^Merge"), regexp.MustCompile("WIP")}
+	list := NewItemList([]string{"abc1234 fix", "Merge abc5678", "\x1B[31mMerge\x1B[0m def5678", "def9012 WIP", "fed3456 feat"})
+
+	if len(list.items) != 2 || list.items[0].number != 1 || list.items[1].number != 5 {
+		t.Error("Incorrect excluded lines")
+	}
+}
+
+func TestHeader(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9]+")
+	config.header = 2
+	list := NewItemList([]string{"PID 1", "\x1B[31m[x]\x1B[0m", "a 3"})
+
+	if len(list.pinned) != 2 || list.pinned[0] != "PID 1" || list.pinned[1] != "[maroon:][x[][-:-:]" {
+		t.Error("Incorrect pinned lines", list.pinned)
+	}
+	if len(list.items) != 1 || list.items[0].number != 3 {
+		t.Error("Incorrect lines below the header")
+	}
+
+	// Lists of JSON Lines and tables pin the lines alike
+	config.header = 1
+	list = NewJsonItemList([]string{"\x01 1", "{\"a\": 1}"})
+	if len(list.pinned) != 1 || list.pinned[0] != "\\x01 1" || len(list.items) != 1 {
+		t.Error("Incorrect pinned lines of JSON Lines", list.pinned)
+	}
+	config.header = 2
+	config.table = tableCsv
+	list, _ = NewTableItemList([]string{"\x01 1", "A,B", "1,2"})
+	if len(list.pinned) != 2 || list.pinned[0] != "\\x01 1" || len(list.items) != 1 {
+		t.Error("Incorrect pinned lines of table", list.pinned)
+	}
+}
+
+func TestEscapeBinary(t *testing.T) {
+	for line, expected := range map[string]string{
+		"a\tb": "a\tb",
+		"a\x00b\x7f": "a\\x00b\\x7f",
+		"\xff\xfeä": "\\xff\\xfeä",
+		"\x1B[31mred\x1B[0m": "\x1B[31mred\x1B[0m",
+	} {
+		if escapeBinary(line) != expected {
+			t.Error("Incorrect escaped line " + escapeBinary(line))
+		}
+	}
+
+	// Only the display is escaped, while the match keeps the bytes for COMMAND
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[^ ]+$")
+	config.program = "ls"
+	item := Item{}
+	item.process("a\x00 caf\xe9.txt")
+	if item.original != "a\x00 caf\xe9.txt" || item.match != "caf\xe9.txt" || item.PrintCommand() != "ls caf\xe9.txt" {
+		t.Error("Incorrect binary match", item.match)
+	}
+	if item.display != "a\\x00 [::-][::r]caf\\xe9.txt[::-]" || item.matchStart != 6 || item.matchEnd != 17 {
+		t.Error("Incorrect display of binary line", item.display, item.matchStart, item.matchEnd)
+	}
+	if !item.SelectMatchAt(7) || item.SelectMatchAt(2) {
+		t.Error("Incorrect column of binary line")
+	}
+}
+
+func TestTruncateDisplay(t *testing.T) {
+	if truncateDisplay("ab[red]cd[-:-:-]ef", 3) != "ab[red]c[-:-:-]…" {
+		t.Error("Incorrect truncated line")
+	}
+	if truncateDisplay("abc", 3) != "abc" {
+		t.Error("Incorrect short line")
+	}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("y+")
+	item := Item{}
+	item.process(strings.Repeat("x", maxDisplayWidth + 10) + "yy" + strings.Repeat("x", maxDisplayWidth))
+	if item.match != "yy" || !strings.HasSuffix(item.display, "[::-][::r]yy[::-][-:-:-]…") {
+		t.Error("Incorrect display of long line")
+	}
+}
+
+func TestSeparators(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile(".+")
+	list := NewItemList([]string{"a", "", " ", "b"})
+	if !list.items[1].separator || !list.items[2].separator || list.items[2].HasMatch() {
+		t.Error("Blank lines are no separators")
+	}
+
+	if list.Selectable(1, 1) != 3 || list.Selectable(2, -1) != 0 || list.Selectable(3, 1) != 3 {
+		t.Error("Incorrect selectable line")
+	}
+
+	list.Unique(true)
+	if len(list.items) != 4 {
+		t.Error("Separators collapsed")
+	}
+}
+
 func TestSort(t *testing.T) {
 	list := &ItemList {
 		items: make([]Item, 3),
@@ -203,3 +330,323 @@ func TestSort(t *testing.T) {
 		t.Error("Incorrect order after sorting")
 	}
 }
+
+func TestSortBy(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("file\\S*")
+	list := NewItemList([]string{"b file10", "a none", "c file2", "d file-1.5"})
+
+	order := func() string {
+		numbers := []string{}
+		for _, item := range list.items {
+			numbers = append(numbers, strconv.Itoa(item.number))
+		}
+		return strings.Join(numbers, " ")
+	}
+
+	list.SortBy(sortNatural)
+	if order() != "4 3 1 2" {
+		t.Error("Incorrect natural order", order())
+	}
+	list.SortBy(sortNumeric)
+	if order() != "4 3 1 2" || list.order != sortNumeric {
+		t.Error("Incorrect numeric order", order())
+	}
+	list.SortBy(sortLine)
+	if order() != "2 1 3 4" {
+		t.Error("Incorrect line order", order())
+	}
+	list.SortBy(sortOriginal)
+	if order() != "1 2 3 4" || list.NextOrder() != sortAscending {
+		t.Error("Incorrect original order", order())
+	}
+	if list.Find(3) != 2 || list.Find(5) != -1 {
+		t.Error("Incorrect index of line number")
+	}
+}
+
+func TestSortModes(t *testing.T) {
+	tests := []struct {
+		mode string
+		matches []string
+		expected string
+	}{
+		{"", []string{"10", "9", "b", "a", "-1.5"}, "5 2 1 4 3"},
+		{sortModeNatural, []string{"file10", "file2", "file1", "file2"}, "3 2 4 1"},
+		{sortModeVersion, []string{"1.10.0", "v1.9.0", "1.9", "2.0.0-rc1", "2.0.0", "2.0.0-beta"}, "2 3 1 6 4 5"},
+		{sortModeHumanSize, []string{"1G", "2K", "512", "1.5KiB", "3MB", "none"}, "3 4 2 5 1 6"},
+		{sortModeTime, []string{"10:00", "9:05", "9:05:30", "1:15 pm", "12:30 am"}, "5 2 3 1 4"},
+		{sortModeDate, []string{"2024-03-01", "2023-12-24 18:00:00", "01.02.2024", "unknown", "Jan 15 2024"}, "2 5 3 1 4"},
+	}
+
+	for _, test := range tests {
+		config = &Config{}
+		config.pattern = regexp.MustCompile("^.+$")
+		config.sortMode = test.mode
+		list := NewItemList(test.matches)
+		list.Sort(1)
+
+		numbers := []string{}
+		for _, item := range list.items {
+			numbers = append(numbers, strconv.Itoa(item.number))
+		}
+		if strings.Join(numbers, " ") != test.expected {
+			t.Error("Incorrect order for sort mode", test.mode, numbers)
+		}
+	}
+}
+
+func TestSortStable(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9]+")
+	config.sortMode = sortModeNatural
+	list := NewItemList([]string{"c 2", "a 1", "b 2", "no match", "d 1"})
+
+	list.Sort(-1)
+	if list.items[0].number != 1 || list.items[1].number != 3 || list.items[2].number != 2 || list.items[3].number != 5 || list.items[4].number != 4 {
+		t.Error("Incorrect order of equal matches")
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	if compareNatural("file2", "file10") >= 0 || compareNatural("file10", "file2") <= 0 || compareNatural("a01", "a1") != 0 ||
+		compareNatural("a", "ab") >= 0 || compareNatural("b1", "a2") <= 0 {
+		t.Error("Incorrect natural comparison")
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	lines := []string{"no match", "key=value"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("(\\w+)=(\\w+)")
+	items := NewItemList(lines)
+
+	env := items.Get(1).Environment(1)
+	expected := []string{"LISST_MATCH=key", "LISST_LINE=key=value", "LISST_INDEX=2", "LISST_NUMBER=2", "LISST_SELECTED=key",
+		"LISST_PATTERN=(\\w+)=(\\w+)", "LISST_GROUP_1=key", "LISST_GROUP_2=value"}
+	if strings.Join(env, "\n") != strings.Join(expected, "\n") {
+		t.Error("Incorrect environment variables")
+	}
+}
+
+func TestExecuted(t *testing.T) {
+	list := &ItemList {
+		items: make([]Item, 3),
+	}
+
+	list.items[0] = Item{
+		display: "line1",
+		match: "test",
+		exitStatus: "0",
+	}
+	list.items[1] = Item{
+		display: "line2",
+		match: "test",
+		exitStatus: "1",
+	}
+	list.items[2] = Item{
+		display: "line3",
+		match: "test",
+	}
+
+	if list.NumExecuted() != 2 {
+		t.Error("Incorrect number of executed lines")
+	}
+	if list.items[0].Failed() || !list.items[1].Failed() || list.items[2].Failed() {
+		t.Error("Incorrect failed lines")
+	}
+	if list.items[0].Unvisited() || list.items[1].Unvisited() || !list.items[2].Unvisited() {
+		t.Error("Incorrect unvisited lines")
+	}
+	if list.items[0].Display(true) != "[green::b]✓[-::-] line1" || list.items[1].Display(true) != "[red::b]✗[-::-] line2" ||
+		list.items[2].Display(true) != "  line3" || list.items[2].Display(false) != "line3" {
+		t.Error("Incorrect markers")
+	}
+}
+
+func TestSelectMatchAt(t *testing.T) {
+	lines := []string{"a1 b2\tc3"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[a-z]([0-9])")
+	items := NewItemList(lines)
+	item := items.Get(0)
+
+	if item.match != "1" {
+		t.Error("Incorrect first match")
+	}
+	if !item.SelectMatchAt(4) || item.match != "2" || item.display != "a1 b[::-][::r]2[::-]    c3" {
+		t.Error("Incorrect match selected by column")
+	}
+	if !item.SelectMatchAt(9) || item.match != "3" {
+		t.Error("Incorrect match selected after tab")
+	}
+	if item.SelectMatchAt(2) || item.SelectMatchAt(20) || item.match != "3" {
+		t.Error("Match selected outside of a match")
+	}
+
+	// Wide characters take two columns
+	item = NewItemList([]string{"日本 a1 😀 b2"}).Get(0)
+	if !item.SelectMatchAt(11) || item.match != "2" || item.matchStart != 12 || item.matchEnd != 13 {
+		t.Error("Incorrect match selected after wide characters")
+	}
+	if item.SelectMatchAt(8) || item.match != "2" {
+		t.Error("Match selected on a wide character")
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	if displayWidth("a\tb") != 6 || displayWidth("日本") != 4 || displayWidth("e\u0301😀") != 3 || displayWidth("a\nb") != 3 {
+		t.Error("Incorrect display width")
+	}
+	if truncateDisplay("日本語", 3) != "日[-:-:-]…" {
+		t.Error("Incorrect truncated line with wide characters")
+	}
+}
+
+func TestMatchColumns(t *testing.T) {
+	lines := []string{"\tx a1", "no match"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[a-z]([0-9])")
+	items := NewItemList(lines)
+
+	if items.items[0].matchStart != 7 || items.items[0].matchEnd != 8 {
+		t.Error("Incorrect columns of the match")
+	}
+	if items.items[1].matchStart != 0 || items.items[1].matchEnd != 0 {
+		t.Error("Incorrect columns without match")
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	lines := []string{"a", "b1", "c", "d", "e", "f", "g", "h", "i", "j2"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9]")
+	config.lineNumbers = true
+	theme = themes["default"]
+	items := NewItemList(lines)
+	items.Filter()
+
+	if items.Get(0).number != 2 || items.Get(1).number != 10 {
+		t.Error("Incorrect line numbers")
+	}
+	if items.Get(1).Placeholders()["n"] != "10" {
+		t.Error("Incorrect placeholder")
+	}
+	if items.Display(0, items.Gutter()) != "[::d] 2[::-] b[::-][::r]1[::-]" || items.GutterWidth(0, items.Gutter()) != 3 {
+		t.Error("Incorrect gutter")
+	}
+}
+
+func TestUnique(t *testing.T) {
+	lines := []string{"a.go:1", "b.go:2", "a.go:3", "no match", "a.go:1", "no match"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("^\\w+\\.go")
+	theme = themes["default"]
+	list := NewItemList(lines)
+	list.Unique(false)
+
+	if len(list.items) != 4 || len(list.items[0].duplicates) != 2 || list.items[0].Display(false) != "[::-][::r]a.go[::-]:1 [::d]×3[::-]" {
+		t.Error("Incorrect lines collapsed by match")
+	}
+
+	if !list.Toggle(0) || len(list.items) != 6 || list.items[1].number != 3 || list.items[2].number != 5 {
+		t.Error("Incorrect expanded lines")
+	}
+	list.items[2].exitStatus = "0"
+	if !list.Toggle(0) || len(list.items) != 4 || list.items[0].duplicates[1].exitStatus != "0" {
+		t.Error("Incorrect collapsed lines")
+	}
+	if list.Toggle(1) {
+		t.Error("Line without duplicates expanded")
+	}
+
+	list = NewItemList(lines)
+	list.Unique(true)
+	if len(list.items) != 4 || len(list.items[0].duplicates) != 1 || len(list.items[3].duplicates) != 1 {
+		t.Error("Incorrect lines collapsed by line")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	lines := []string{"a.go:1", "b.go:2", "no match", "a.go:3"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("^\\w+\\.go")
+	theme = themes["default"]
+	list := NewItemList(lines)
+	list.Group(nil)
+
+	numbers := []int{}
+	for _, item := range list.items {
+		numbers = append(numbers, item.number)
+	}
+	if !slices.Equal(numbers, []int{-1, 1, 4, -2, 2, 3}) || list.NumMatches() != 3 {
+		t.Error("Incorrect grouped lines", numbers)
+	}
+	if list.Display(0, list.Gutter()) != "[::-][::r]a.go[::-] [::d]×2[::-]" || list.Display(1, list.Gutter()) != "  [::-][::r]a.go[::-]:1" ||
+		list.GutterWidth(1, list.Gutter()) != 2 {
+		t.Error("Incorrect display of a group")
+	}
+	if list.Header(2) != 0 || list.Header(4) != 3 || len(list.Get(0).Placeholders()) != 0 {
+		t.Error("Incorrect header")
+	}
+	if slices.ContainsFunc(list.Get(0).Environment(0), func(variable string) bool {
+		return strings.HasPrefix(variable, "LISST_NUMBER=")
+	}) {
+		t.Error("Incorrect line number of header")
+	}
+
+	list.Toggle(0)
+	if len(list.items) != 4 || list.NumMatches() != 3 {
+		t.Error("Incorrect collapsed group")
+	}
+
+	list.SortBy(sortDescending)
+	numbers = []int{}
+	for _, item := range list.items {
+		numbers = append(numbers, item.number)
+	}
+	if !slices.Equal(numbers, []int{-2, 2, -1, 3}) || !list.grouped || list.items[2].expanded {
+		t.Error("Incorrect sorted groups", numbers)
+	}
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	lines := []string{"a.go:1", "b.go:2", "a.go:3", "a.go:4"}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("^\\w+\\.go")
+	theme = themes["default"]
+	list := NewItemList(lines)
+	list.Unique(false)
+	list.Toggle(0)
+	list.Group(nil)
+
+	// The expanded duplicates are nested in the group as well
+	numbers := []int{}
+	for _, item := range list.items {
+		numbers = append(numbers, item.number)
+	}
+	if !slices.Equal(numbers, []int{-1, 1, 3, 4, -2, 2}) || len(list.items[1].duplicates) != 2 || !list.items[1].expanded {
+		t.Error("Incorrect grouped duplicates", numbers)
+	}
+
+	list.Toggle(1)
+	if len(list.items) != 4 || len(list.items[1].duplicates) != 2 || list.items[0].Display(false) != "[::-][::r]a.go[::-] [::d]×3[::-]" {
+		t.Error("Incorrect collapsed duplicates in a group")
+	}
+	list.Toggle(0)
+	list.Toggle(0)
+	if len(list.items) != 4 || list.items[1].number != 1 || list.items[1].expanded || len(list.items[1].duplicates) != 2 {
+		t.Error("Incorrect group toggled with collapsed duplicates")
+	}
+	list.Toggle(1)
+	if len(list.items) != 6 || !list.items[2].child || list.items[3].number != 4 {
+		t.Error("Incorrect duplicates expanded in a group")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fields like {.level} or {.user.name} in the template of --display
+var reJsonField = regexp.MustCompile("\\{(\\.[^{}\\s]*)\\}")
+
+func NewJsonItemList(input []string) *ItemList {
+	pinned, items := splitInput(input)
+	list := newItemList(pinned)
+	for _, item := range items {
+		if item.separator {
+			list.items = append(list.items, item)
+			continue
+		}
+
+		line := reAnsiColorCodes.ReplaceAllString(item.line, "")
+		value, err := parseJson(line)
+		if err != nil {
+			item.invalid = true
+			item.process(line)
+			list.items = append(list.items, item)
+			continue
+		}
+
+		item.values = map[string]string{}
+		flattenJson(value, "", item.values)
+		text, start, end := renderJson(line, item.values)
+		if config.matchField != "" {
+			// Match the value of --match-field only
+			item.column = true
+			item.columnStart = start
+			item.columnEnd = end
+		}
+		item.process(text)
+		list.items = append(list.items, item)
+	}
+	return list
+}
+
+// Parses a single JSON value, where numbers keep their text to not lose the precision of large IDs
+func parseJson(line string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return value, nil
+}
+
+// Stores all values of the given JSON value by their path like .a.b or .a[0]
+func flattenJson(value any, path string, values map[string]string) {
+	if path != "" {
+		values[path] = jsonString(value)
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			flattenJson(child, path + "." + key, values)
+		}
+	case []any:
+		for i, child := range value {
+			flattenJson(child, path + "[" + strconv.Itoa(i) + "]", values)
+		}
+	}
+}
+
+// Returns strings without quotes and everything else as compact JSON
+func jsonString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Renders the line with the template of --display and returns the byte positions of --match-field
+func renderJson(line string, values map[string]string) (string, int, int) {
+	text := strings.TrimSpace(line)
+	start, end := -1, -1
+	if config.display != "" {
+		var builder strings.Builder
+		last := 0
+		for _, index := range reJsonField.FindAllStringSubmatchIndex(config.display, -1) {
+			builder.WriteString(config.display[last:index[0]])
+			path := config.display[index[2]:index[3]]
+			if path == config.matchField && start < 0 {
+				start = builder.Len()
+				end = start + len(values[path])
+			}
+			// Keep multi-line values on one line
+			builder.WriteString(strings.ReplaceAll(values[path], "\n", " "))
+			last = index[1]
+		}
+		builder.WriteString(config.display[last:])
+		text = builder.String()
+	}
+
+	if config.matchField != "" && start < 0 {
+		// Append the value of --match-field if the line does not show it
+		value, found := values[config.matchField]
+		if !found {
+			return text, len(text), len(text)
+		}
+		start = len(text) + 2
+		end = start + len(value)
+		text += "  " + strings.ReplaceAll(value, "\n", " ")
+	}
+	return text, start, end
+}
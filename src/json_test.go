@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestFlattenJson(t *testing.T) {
+	values := map[string]string{}
+	flattenJson(map[string]any{"a": map[string]any{"b": 1.5}, "c": []any{"x", true}, "d": nil}, "", values)
+
+	expected := map[string]string{".a": "{\"b\":1.5}", ".a.b": "1.5", ".c": "[\"x\",true]", ".c[0]": "x", ".c[1]": "true", ".d": "null"}
+	if len(values) != len(expected) {
+		t.Error("Incorrect number of fields", values)
+	}
+	for path, value := range expected {
+		if values[path] != value {
+			t.Error("Incorrect value of " + path + ": " + values[path])
+		}
+	}
+}
+
+func TestRenderJson(t *testing.T) {
+	config = &Config{}
+	config.display = "{.level} {.msg}"
+	config.matchField = ".msg"
+	values := map[string]string{".level": "info", ".msg": "multi\nline"}
+	text, start, end := renderJson("", values)
+	if text != "info multi line" || text[start:end] != "multi line" {
+		t.Error("Incorrect rendering", text, start, end)
+	}
+
+	// The field is appended if the template does not show it
+	config.matchField = ".id"
+	values[".id"] = "r1"
+	text, start, end = renderJson("", values)
+	if text != "info multi line  r1" || text[start:end] != "r1" {
+		t.Error("Incorrect rendering of appended field", text, start, end)
+	}
+}
+
+func TestJsonItemList(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("^(?s).+$")
+	config.matchField = ".id"
+	list := NewJsonItemList([]string{"{\"id\": \"r1\", \"user\": {\"name\": \"ann\"}}", "{invalid", "{\"msg\": \"r2\"}"})
+
+	if len(list.items) != 3 || list.items[0].match != "r1" || list.items[0].Placeholders()[".user.name"] != "ann" {
+		t.Error("Incorrect JSON line")
+	}
+	if !list.items[1].invalid || list.items[1].HasMatch() {
+		t.Error("Invalid JSON line not detected")
+	}
+	if list.items[2].HasMatch() {
+		t.Error("Incorrect match of missing field")
+	}
+}
+
+func TestJsonNumbers(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("^(?s).+$")
+	config.matchField = ".id"
+	list := NewJsonItemList([]string{"{\"id\": 12345678901234567890, \"n\": 9007199254740993, \"a\": [1.50]}", "{\"id\": 1} x"})
+
+	// Numbers keep their text instead of becoming float64
+	placeholders := list.items[0].Placeholders()
+	if list.items[0].match != "12345678901234567890" || placeholders[".n"] != "9007199254740993" || placeholders[".a"] != "[1.50]" {
+		t.Error("Incorrect numbers", placeholders)
+	}
+	if !list.items[1].invalid {
+		t.Error("Trailing text after JSON not detected")
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"github.com/gdamore/tcell/v2"
+)
+
+type Action struct {
+	name string
+	description string
+	repeatable bool
+}
+
+type Keymap struct {
+	// Key sequence separated by spaces mapped to the name of an action
+	bindings map[string]string
+	// Key sequences in the order they have been bound
+	sequences []string
+	// Number typed before a key repeats the action
+	counts bool
+}
+
+// All actions in the order of the help
+var actions = []Action{
+	{"quit", "Quit", false},
+	{"down", "Move to the next line", true},
+	{"up", "Move to the previous line", true},
+	{"half-page-down", "Move half a page down", true},
+	{"half-page-up", "Move half a page up", true},
+	{"page-down", "Move one page down", true},
+	{"page-up", "Move one page up", true},
+	{"top", "Move to the first line", false},
+	{"bottom", "Move to the last line", false},
+	{"next-match", "Jump to the next line with a match", true},
+	{"prev-match", "Jump to the previous line with a match", true},
+	{"next-unvisited", "Jump to the next match where COMMAND has not been executed", true},
+	{"prev-unvisited", "Jump to the previous match where COMMAND has not been executed", true},
+	{"scroll-left", "Scroll long lines to the left", true},
+	{"scroll-right", "Scroll long lines to the right", true},
+	{"wrap", "Toggle wrapping long lines over multiple rows", false},
+	{"sort", "Cycle through the sort orders of the lines", false},
+	{"expand", "Show or hide the lines collapsed by --unique into the selected line", false},
+	{"filter", "Cycle through showing all lines, matching lines and non-matching lines", false},
+	{"reload", "Read the input of -f or -c again", false},
+	{"history", "Show all commands executed in this session", false},
+	{"help", "Show the key bindings and the current settings", false},
+	{"execute", "Execute COMMAND with the PATTERN match as argument", false},
+	{"execute-confirm", "Execute COMMAND after confirmation", false},
+}
+
+var keyPresets = map[string]map[string][]string{
+	"default": {
+		"quit": {"q", "Esc"},
+		"down": {"Down"},
+		"up": {"Up"},
+		"page-down": {"PgDn"},
+		"page-up": {"PgUp"},
+		"top": {"Home"},
+		"bottom": {"End"},
+		"next-match": {"n"},
+		"prev-match": {"N"},
+		"next-unvisited": {"u"},
+		"prev-unvisited": {"U"},
+		"scroll-left": {"Left"},
+		"scroll-right": {"Right"},
+		"wrap": {"w"},
+		"sort": {"s"},
+		"expand": {"Tab"},
+		"filter": {"f"},
+		"reload": {"r", "F5"},
+		"history": {"h"},
+		"help": {"?", "F1"},
+		"execute": {"Enter"},
+	},
+	"vi": {
+		"quit": {"q", "Esc"},
+		"down": {"Down", "j"},
+		"up": {"Up", "k"},
+		"half-page-down": {"Ctrl-D"},
+		"half-page-up": {"Ctrl-U"},
+		"page-down": {"PgDn", "Ctrl-F"},
+		"page-up": {"PgUp", "Ctrl-B"},
+		"top": {"Home", "gg"},
+		"bottom": {"End", "G"},
+		"next-match": {"n"},
+		"prev-match": {"N"},
+		"next-unvisited": {"u"},
+		"prev-unvisited": {"U"},
+		"scroll-left": {"Left", "zh"},
+		"scroll-right": {"Right", "zl"},
+		"wrap": {"w"},
+		"sort": {"s"},
+		"expand": {"Tab"},
+		"filter": {"f"},
+		"reload": {"r", "F5"},
+		"history": {"h"},
+		"help": {"?", "F1"},
+		"execute": {"Enter"},
+	},
+	"emacs": {
+		"quit": {"q", "Esc", "Ctrl-G"},
+		"down": {"Down", "Ctrl-N"},
+		"up": {"Up", "Ctrl-P"},
+		"page-down": {"PgDn", "Ctrl-V"},
+		"page-up": {"PgUp", "Alt-v"},
+		"top": {"Home", "Alt-<"},
+		"bottom": {"End", "Alt->"},
+		"next-match": {"n"},
+		"prev-match": {"N"},
+		"next-unvisited": {"u"},
+		"prev-unvisited": {"U"},
+		"scroll-left": {"Left"},
+		"scroll-right": {"Right"},
+		"wrap": {"w"},
+		"sort": {"s"},
+		"expand": {"Tab"},
+		"filter": {"f"},
+		"reload": {"r", "F5"},
+		"history": {"h"},
+		"help": {"?", "F1"},
+		"execute": {"Enter"},
+	},
+}
+
+// Names of all special keys, e.g. Enter or Ctrl-D
+var keyNames = map[string]bool{}
+
+func init() {
+	for _, name := range tcell.KeyNames {
+		keyNames[name] = true
+	}
+}
+
+func NewKeymap(preset string, overrides map[string][]string) (*Keymap, error) {
+	bindings, ok := keyPresets[preset]
+	if !ok {
+		return nil, errors.New("Invalid key preset " + preset)
+	}
+
+	keymap := &Keymap{
+		bindings: map[string]string{},
+		counts: preset == "vi",
+	}
+
+	for _, action := range actions {
+		keys := bindings[action.name]
+		if override, ok := overrides[action.name]; ok {
+			keys = override
+		}
+		for _, key := range keys {
+			err := keymap.bind(key, action.name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for name := range overrides {
+		if findAction(name) == nil {
+			return nil, errors.New("Invalid action " + name)
+		}
+	}
+
+	return keymap, keymap.checkPrefixes()
+}
+
+func (keymap *Keymap) bind(key string, action string) error {
+	sequence, err := parseKeySequence(key)
+	if err != nil {
+		return err
+	}
+
+	if other, ok := keymap.bindings[sequence]; ok && other != action {
+		return fmt.Errorf("Key %s is bound to both %s and %s", key, other, action)
+	}
+
+	if keymap.counts && strings.ContainsAny(sequence[:1], "123456789") {
+		return fmt.Errorf("Key %s of %s is used for counts", key, action)
+	}
+
+	if _, ok := keymap.bindings[sequence]; !ok {
+		keymap.sequences = append(keymap.sequences, sequence)
+	}
+	keymap.bindings[sequence] = action
+	return nil
+}
+
+func (keymap *Keymap) checkPrefixes() error {
+	// A key sequence must not start with another key sequence, e.g. g and gg
+	for _, sequence := range keymap.sequences {
+		for _, other := range keymap.sequences {
+			if strings.HasPrefix(other, sequence + " ") {
+				return fmt.Errorf("Key %s of %s is a prefix of %s of %s", printKeySequence(sequence), keymap.bindings[sequence],
+					printKeySequence(other), keymap.bindings[other])
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the action of a complete key sequence and whether the sequence might still be completed
+func (keymap *Keymap) Lookup(sequence []string) (string, bool) {
+	key := strings.Join(sequence, " ")
+	if action, ok := keymap.bindings[key]; ok {
+		return action, false
+	}
+
+	for other := range keymap.bindings {
+		if strings.HasPrefix(other, key + " ") {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+func (keymap *Keymap) Keys(action string) []string {
+	keys := []string{}
+	for _, sequence := range keymap.sequences {
+		if keymap.bindings[sequence] == action {
+			keys = append(keys, printKeySequence(sequence))
+		}
+	}
+	return keys
+}
+
+func (keymap *Keymap) PrintHelp() []string {
+	lines := []string{}
+	for _, action := range actions {
+		keys := keymap.Keys(action.name)
+		if len(keys) == 0 {
+			continue
+		}
+
+		text := "[" + strings.Join(keys, "] or [") + "]"
+		if len(keys) > 2 {
+			text = "[" + strings.Join(keys[:len(keys) - 1], "], [") + "] or [" + keys[len(keys) - 1] + "]"
+		}
+
+		if len(text) < 20 {
+			lines = append(lines, fmt.Sprintf("%-20s%s", text, action.description))
+		} else {
+			lines = append(lines, text, strings.Repeat(" ", 20) + action.description)
+		}
+	}
+
+	if keymap.counts {
+		lines = append(lines, fmt.Sprintf("%-20s%s", "[1-9]", "Repeat the following movement"))
+	}
+	return lines
+}
+
+func findAction(name string) *Action {
+	for i := range actions {
+		if actions[i].name == name {
+			return &actions[i]
+		}
+	}
+	return nil
+}
+
+func parseKeySequence(key string) (string, error) {
+	if keyNames[key] || utf8.RuneCountInString(key) == 1 {
+		return key, nil
+	} else if strings.HasPrefix(key, "Alt-") && utf8.RuneCountInString(key) == 5 {
+		return key, nil
+	} else if key == "" || strings.ContainsAny(key, " -") {
+		return "", errors.New("Invalid key " + key)
+	}
+
+	// Sequence of characters, e.g. gg
+	return strings.Join(strings.Split(key, ""), " "), nil
+}
+
+func printKeySequence(sequence string) string {
+	if isSpecialKey(sequence) {
+		return sequence
+	}
+	return strings.ReplaceAll(sequence, " ", "")
+}
+
+func isSpecialKey(key string) bool {
+	return keyNames[key] || strings.HasPrefix(key, "Alt-")
+}
+
+func KeyName(event *tcell.EventKey) string {
+	name := string(event.Rune())
+	if event.Key() != tcell.KeyRune {
+		name = tcell.KeyNames[event.Key()]
+	}
+	if event.Modifiers() & tcell.ModAlt != 0 {
+		name = "Alt-" + name
+	}
+	return name
+}
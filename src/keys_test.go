@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestKeymapPresets(t *testing.T) {
+	for preset := range keyPresets {
+		_, err := NewKeymap(preset, map[string][]string{})
+		if err != nil {
+			t.Error("Invalid preset", preset, err)
+		}
+	}
+
+	_, err := NewKeymap("foobar", map[string][]string{})
+	if err == nil {
+		t.Error("Incorrect unknown preset")
+	}
+}
+
+func TestKeymapOverrides(t *testing.T) {
+	keymap, err := NewKeymap("vi", map[string][]string{"quit": {"Ctrl-Q"}, "execute-confirm": {"x", "Alt-x"}})
+	if err != nil {
+		t.Fatal("Incorrect overrides", err)
+	}
+
+	if action, pending := keymap.Lookup([]string{"Ctrl-Q"}); action != "quit" || pending {
+		t.Error("Incorrect overridden key")
+	}
+	if action, _ := keymap.Lookup([]string{"q"}); action != "" {
+		t.Error("Incorrect replaced key")
+	}
+	if action, _ := keymap.Lookup([]string{"Alt-x"}); action != "execute-confirm" {
+		t.Error("Incorrect key with modifier")
+	}
+	if action, pending := keymap.Lookup([]string{"g"}); action != "" || !pending {
+		t.Error("Incorrect incomplete key sequence")
+	}
+	if action, pending := keymap.Lookup([]string{"g", "g"}); action != "top" || pending {
+		t.Error("Incorrect key sequence")
+	}
+	if len(keymap.Keys("top")) != 2 || keymap.Keys("top")[1] != "gg" {
+		t.Error("Incorrect keys of action")
+	}
+}
+
+func TestKeymapConflicts(t *testing.T) {
+	_, err := NewKeymap("default", map[string][]string{"history": {"n"}})
+	if err == nil {
+		t.Error("Incorrect conflict of keys")
+	}
+
+	_, err = NewKeymap("vi", map[string][]string{"history": {"g"}})
+	if err == nil {
+		t.Error("Incorrect conflict of key sequences")
+	}
+
+	_, err = NewKeymap("vi", map[string][]string{"history": {"5"}})
+	if err == nil {
+		t.Error("Incorrect conflict with counts")
+	}
+
+	_, err = NewKeymap("default", map[string][]string{"foobar": {"x"}})
+	if err == nil {
+		t.Error("Incorrect unknown action")
+	}
+
+	_, err = NewKeymap("default", map[string][]string{"history": {"Ctrl-Foo"}})
+	if err == nil {
+		t.Error("Incorrect unknown key")
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	if KeyName(tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone)) != "j" {
+		t.Error("Incorrect name of character key")
+	}
+	if KeyName(tcell.NewEventKey(tcell.KeyCtrlD, 0, tcell.ModCtrl)) != "Ctrl-D" {
+		t.Error("Incorrect name of control key")
+	}
+	if KeyName(tcell.NewEventKey(tcell.KeyRune, '<', tcell.ModAlt)) != "Alt-<" {
+		t.Error("Incorrect name of key with modifier")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Color tags like [red], [::b] or [-:-:-], but not escaped brackets like [foo[]
+var reStyleTag = regexp.MustCompile("\\[([a-zA-Z0-9#-]*)(?::([a-zA-Z0-9#-]*)(?::([a-zA-Z-]*))?)?\\]")
+
+// List which renders long lines over multiple rows in wrap mode and records of several lines in multi-line mode
+type WrapList struct {
+	*tview.List
+	wrap bool
+	multiLine bool
+	// Columns to scroll into view when drawing the list next time
+	revealStart int
+	revealEnd int
+	// Lines pinned above the list, which scroll horizontally with it
+	header *tview.List
+}
+
+func NewWrapList() *WrapList {
+	return &WrapList{
+		List: tview.NewList(),
+		header: nil,
+	}
+}
+
+// Returns a list for the lines pinned above this list, which is never selected
+func (list *WrapList) NewHeader() *tview.List {
+	list.header = tview.NewList()
+	list.header.ShowSecondaryText(false)
+	list.header.SetSelectedFocusOnly(true)
+	list.header.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		return action, nil
+	})
+	return list.header
+}
+
+// Returns whether the lines are drawn over multiple rows instead of by tview.List
+func (list *WrapList) Rows() bool {
+	return list.wrap || list.multiLine
+}
+
+func (list *WrapList) Reveal(start int, end int) {
+	list.revealStart = start
+	list.revealEnd = end
+}
+
+func (list *WrapList) Draw(screen tcell.Screen) {
+	if list.header != nil {
+		// Draw the header again with the horizontal offset of this draw
+		defer list.drawHeader(screen)
+	}
+
+	if !list.Rows() {
+		list.scrollToReveal()
+		list.List.Draw(screen)
+		return
+	}
+
+	list.DrawForSubclass(screen, list)
+	x, y, width, height := list.GetInnerRect()
+	if height <= 0 || width <= 0 {
+		return
+	}
+
+	// Keep the current line in view, where only the lines which fit above it are wrapped
+	current := list.GetCurrentItem()
+	offset, _ := list.GetOffset()
+	first := current
+	rows := len(list.wrapItem(current, width))
+	for first > offset {
+		rows += len(list.wrapItem(first - 1, width))
+		if rows > height {
+			break
+		}
+		first--
+	}
+	offset = first
+	list.SetOffset(offset, 0)
+
+	selected := ParseStyle(theme.selected)
+	row := 0
+	for index := offset; index < list.GetItemCount() && row < height; index++ {
+		for _, line := range list.wrapItem(index, width) {
+			if row >= height {
+				break
+			}
+			tview.Print(screen, strings.TrimSuffix(line, "\n"), x, y + row, width, tview.AlignLeft, tview.Styles.PrimaryTextColor)
+			if index == current {
+				highlightRow(screen, x, y + row, width, selected)
+			}
+			row++
+		}
+	}
+}
+
+func (list *WrapList) drawHeader(screen tcell.Screen) {
+	_, horizontal := list.GetOffset()
+	_, _, width, _ := list.header.GetInnerRect()
+	for i := 0; i < list.header.GetItemCount(); i++ {
+		// Pad the lines as tview.List does not scroll beyond the end of short lines
+		text, _ := list.header.GetItemText(i)
+		list.header.SetItemText(i, strings.TrimRight(text, " ") + strings.Repeat(" ", horizontal + width), "")
+	}
+	list.header.SetOffset(0, horizontal)
+	list.header.Draw(screen)
+}
+
+func (list *WrapList) scrollToReveal() {
+	_, _, width, _ := list.GetInnerRect()
+	if list.revealEnd == 0 || width <= 0 {
+		return
+	}
+
+	items, horizontal := list.GetOffset()
+	if list.revealStart < horizontal {
+		horizontal = list.revealStart
+	} else if list.revealEnd > horizontal + width {
+		horizontal = min(list.revealStart, list.revealEnd - width)
+	}
+	list.SetOffset(items, horizontal)
+	list.Reveal(0, 0)
+}
+
+// Returns the index of the line shown in the given row of the list or -1
+func (list *WrapList) IndexAtRow(row int) int {
+	offset, _ := list.GetOffset()
+	if !list.Rows() {
+		if offset + row < list.GetItemCount() {
+			return offset + row
+		}
+		return -1
+	}
+
+	_, _, width, _ := list.GetInnerRect()
+	for index := offset; index < list.GetItemCount(); index++ {
+		rows := len(list.wrapItem(index, width))
+		if row < rows {
+			return index
+		}
+		row -= rows
+	}
+	return -1
+}
+
+// Returns the column within the line of the given index shown at the given row and column of the list
+func (list *WrapList) ColumnAt(index int, row int, column int) int {
+	offset, horizontal := list.GetOffset()
+	if !list.Rows() {
+		return column + horizontal
+	}
+
+	// Add the widths of the rows before the clicked one
+	_, _, width, _ := list.GetInnerRect()
+	for i := offset; i < index; i++ {
+		row -= len(list.wrapItem(i, width))
+	}
+	lines := list.wrapItem(index, width)
+	for i := 0; i < row && i < len(lines); i++ {
+		column += tview.TaggedStringWidth(strings.TrimSuffix(lines[i], "\n"))
+		if strings.HasSuffix(lines[i], "\n") {
+			// Count the line break of multi-line records
+			column++
+		}
+	}
+	return column
+}
+
+// Returns an event at the row tview.List expects for the line of the given index
+func (list *WrapList) ItemEvent(index int, event *tcell.EventMouse) *tcell.EventMouse {
+	if !list.Rows() {
+		return event
+	}
+	x, _ := event.Position()
+	_, y, _, _ := list.GetInnerRect()
+	offset, _ := list.GetOffset()
+	return tcell.NewEventMouse(x, y + index - offset, event.Buttons(), event.Modifiers())
+}
+
+// Returns the rows of the line of the given index, where rows ending a line of a multi-line record end with a newline
+func (list *WrapList) wrapItem(index int, width int) []string {
+	text, _ := list.GetItemText(index)
+	lines := []string{}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if !list.wrap {
+			lines = append(lines, line)
+			continue
+		}
+		rows := tview.WordWrap(strings.TrimSuffix(line, "\n"), width)
+		if len(rows) > 0 && strings.HasSuffix(line, "\n") {
+			rows[len(rows) - 1] += "\n"
+		}
+		lines = append(lines, rows...)
+	}
+	if len(lines) == 0 {
+		return []string{""}
+	}
+
+	// Continue the colors of the previous rows
+	tags := ""
+	for i := range lines {
+		line := lines[i]
+		lines[i] = tags + line
+		tags += strings.Join(reStyleTag.FindAllString(line, -1), "")
+	}
+	return lines
+}
+
+// Applies the style of the selected line on top of the colors of the text
+func highlightRow(screen tcell.Screen, x int, y int, width int, style tcell.Style) {
+	fg, bg, attributes := style.Decompose()
+	for i := x; i < x + width; i++ {
+		mainc, combc, cellStyle, _ := screen.GetContent(i, y)
+		cellFg, cellBg, cellAttributes := cellStyle.Decompose()
+		if cellFg == tcell.ColorDefault || cellFg == tview.Styles.PrimaryTextColor {
+			cellFg = fg
+		}
+		if cellBg == tcell.ColorDefault || cellBg == tview.Styles.PrimitiveBackgroundColor {
+			cellBg = bg
+		}
+		screen.SetContent(i, y, mainc, combc, cellStyle.Foreground(cellFg).Background(cellBg).Attributes(cellAttributes | attributes))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestWrapItem(t *testing.T) {
+	list := NewWrapList()
+	list.wrap = true
+	list.AddItem("abc [red]def ghi[-:-:-] jkl", "", 0, nil)
+
+	lines := list.wrapItem(0, 8)
+	if len(lines) != 2 || lines[0] != "abc [red]def " || lines[1] != "[red]ghi[-:-:-] jkl" {
+		t.Error("Incorrect wrapped lines", lines)
+	}
+
+	list.AddItem("", "", 0, nil)
+	if len(list.wrapItem(1, 8)) != 1 {
+		t.Error("Incorrect wrapped empty line")
+	}
+}
+
+func TestMultiLineItem(t *testing.T) {
+	list := NewWrapList()
+	list.multiLine = true
+	list.AddItem("abc def\n[red]ghi", "", 0, nil)
+
+	lines := list.wrapItem(0, 4)
+	if len(lines) != 2 || lines[0] != "abc def\n" || lines[1] != "[red]ghi" {
+		t.Error("Incorrect rows of multi-line record", lines)
+	}
+
+	list.wrap = true
+	list.SetRect(0, 0, 4, 10)
+	lines = list.wrapItem(0, 4)
+	if len(lines) != 3 || lines[1] != "def\n" || lines[2] != "[red]ghi" {
+		t.Error("Incorrect wrapped rows of multi-line record", lines)
+	}
+	if list.ColumnAt(0, 2, 1) != 9 {
+		t.Error("Incorrect column in multi-line record")
+	}
+}
+
+func TestDrawWrapped(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	screen.SetSize(4, 5)
+	list := NewWrapList()
+	list.wrap = true
+	list.SetRect(0, 0, 4, 5)
+	for range 3999 {
+		list.AddItem("abc def", "", 0, nil)
+	}
+	list.AddItem("xyz def", "", 0, nil)
+
+	// Jumping to the bottom scrolls just far enough to show the whole current line
+	list.SetCurrentItem(3999)
+	list.Draw(screen)
+	screen.Show()
+	if offset, _ := list.GetOffset(); offset != 3998 {
+		t.Error("Incorrect offset after jumping to the bottom", offset)
+	}
+	if cells, _, _ := screen.GetContents(); string(cells[8].Runes) != "x" {
+		t.Error("Incorrect rows drawn at the bottom")
+	}
+	list.SetCurrentItem(10)
+	list.Draw(screen)
+	if offset, _ := list.GetOffset(); offset != 10 {
+		t.Error("Incorrect offset after jumping up", offset)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
@@ -14,14 +17,23 @@ type Ui struct {
 	pageList *PageList
 	pageText *PageText
 	pageTextVisible bool
+	modalVisible bool
+	helpVisible bool
+	keySequence []string
+	keyCount int
+	dryRunCommands []string
 	config *Config
+	// Stops reloading the input in the background
+	cancelReload context.CancelFunc
 }
 
 type PageList struct {
 	flex *tview.Flex
-	list *tview.List
+	header *tview.List
+	list *WrapList
 	status *tview.TextView
 	itemList *ItemList
+	reloading bool
 }
 
 type PageText struct {
@@ -32,16 +44,57 @@ type PageText struct {
 
 var config *Config
 
+// Number of columns to scroll long lines horizontally
+const scrollStep = 8
+
 func main() {
 	config = NewConfig()
-	input := readFromPipe()
-	itemList := NewItemList(input)
 
-	if config.filter {
-		err := itemList.Filter()
+	var input []string
+	if config.history {
+		input = readFromHistory()
+	} else {
+		input = readFromInput()
+	}
+	itemList, err := buildItemList(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	run(itemList, 0, nil)
+}
+
+// Builds the list of the input lines with all options applied
+func buildItemList(input []string) (*ItemList, error) {
+	var itemList *ItemList
+	if config.jsonl {
+		itemList = NewJsonItemList(input)
+	} else if config.table != "" {
+		var err error
+		itemList, err = NewTableItemList(input)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "All lines filtered out")
-			os.Exit(1)
+			return nil, err
+		}
+	} else {
+		itemList = NewItemList(input)
+	}
+	if len(itemList.items) == 0 {
+		return nil, errors.New("All lines excluded")
+	}
+
+	if config.unique != "" {
+		itemList.Unique(config.unique == "line")
+	}
+
+	if config.filter || config.invertFilter {
+		view := viewMatching
+		if config.invertFilter {
+			view = viewNonMatching
+		}
+		err := itemList.SetView(view)
+		if err != nil {
+			return nil, errors.New("All lines filtered out")
 		}
 	}
 
@@ -49,23 +102,46 @@ func main() {
 		itemList.Sort(config.sort)
 	}
 
-	run(itemList, 0, "", "", "")
+	if config.group {
+		itemList.Group(nil)
+	}
+	return itemList, nil
 }
 
 func PrintHelp() {
-	fmt.Println("Usage: COMMAND_IN | " + os.Args[0] + " [OPTIONS] PATTERN [COMMAND]\n")
+	fmt.Println("Usage: COMMAND_IN | " + os.Args[0] + " [OPTIONS] PATTERN [COMMAND]")
+	fmt.Println("   or: " + os.Args[0] + " -f FILE [-f FILE...] [OPTIONS] PATTERN [COMMAND]")
+	fmt.Println("   or: " + os.Args[0] + " -c COMMAND_IN [OPTIONS] PATTERN [COMMAND]\n")
 	fmt.Println("This program reads the output of COMMAND_IN from the pipe and displays all lines as")
 	fmt.Println("an interactive list. Each line is matched against a regular expression PATTERN. The")
 	fmt.Println("first match in each line is highlighted. When [Enter] is pressed, the given COMMAND")
 	fmt.Println("is executed with the highlighted match of the selected line as additional argument.")
 	fmt.Println("The placeholder `{}` can be used in COMMAND to insert the match at a given position.")
+	fmt.Println("The placeholder `{n}` inserts the number of the selected line in the input, and")
+	fmt.Println("`{src}` the file of -f it has been read from. The match is only appended if COMMAND")
+	fmt.Println("contains no placeholder. For tables, the fields of the selected line are inserted by")
+	fmt.Println("`{1}`, `{2}`, ... or by the names of their columns.")
+	fmt.Println("For JSON Lines, the fields are inserted by their paths like `{.id}` or `{.user.name}`.")
+	fmt.Println("Shell expansions like `${1}` are not placeholders and kept as they are.")
 	fmt.Println("When the COMMAND returns, the list is displayed again.")
+	fmt.Println("\nCOMMAND is run with the following environment variables:")
+	fmt.Println("\n   LISST_MATCH         The highlighted match of the selected line")
+	fmt.Println("   LISST_LINE          The selected line without color codes")
+	fmt.Println("   LISST_INDEX         The position of the selected line in the list")
+	fmt.Println("   LISST_NUMBER        The number of the selected line in the input")
+	fmt.Println("   LISST_SOURCE        The file of -f of the selected line")
+	fmt.Println("   LISST_GROUP_1..n    The capture groups of PATTERN in the selected line")
+	fmt.Println("   LISST_FIELD_1..n    The fields of the selected line of a table")
+	fmt.Println("   LISST_SELECTED      The matches of all selected lines, separated by newlines")
+	fmt.Println("   LISST_PATTERN       The regular expression PATTERN")
 	fmt.Println("\nKey bindings:")
-	fmt.Println("\n   [q] or [Esc]        Quit")
-	fmt.Println("   [Up] and [Down]     Browse lines")
-	fmt.Println("   [n]                 Jump to the next line with a match")
-	fmt.Println("   [N]                 Jump to the previous line with a match")
-	fmt.Println("   [Enter]             Execute COMMAND with the PATTERN match as argument")
+	fmt.Println()
+	for _, line := range config.keymap.PrintHelp() {
+		fmt.Println("   " + line)
+	}
+	fmt.Println("   [Ctrl-C]            Cancel the running COMMAND or reloading the input")
+	fmt.Println("   [y] or [n]          Confirm or decline executing COMMAND with --confirm")
+	fmt.Println("\nKey bindings can be changed in $XDG_CONFIG_HOME/lisst/config, see the README.")
 	fmt.Println("\nKeywords to replace PATTERN:")
 	fmt.Println("\n   --line              Match the whole line")
 	fmt.Println("   --git-commit-hash   Match a Git commit hash")
@@ -77,10 +153,76 @@ func PrintHelp() {
 	fmt.Println("   --user              Match the name of an existing user")
 	fmt.Println("\nOther keyword OPTIONS:")
 	fmt.Println("\n   --show-output       Show the output (both stdout and stderr) of COMMAND")
+	fmt.Println("   --shell             Run COMMAND as a single string with $SHELL -c, where the match")
+	fmt.Println("                       is inserted shell-quoted (do not quote `{}` yourself)")
+	fmt.Println("   --confirm           Ask for confirmation before executing COMMAND")
+	fmt.Println("   --dry-run           Do not execute COMMAND, but print all commands that would have")
+	fmt.Println("                       been executed when quitting")
 	fmt.Println("   --ignore-error      Ignore any error occurring during the execution of COMMAND")
+	fmt.Println("   --exit-on-error     Quit with the exit code of COMMAND if it fails. By default,")
+	fmt.Println("                       the error is shown, the line is marked, and the exit code of")
+	fmt.Println("                       the last failed COMMAND is returned when quitting")
+	fmt.Println("   --timeout DURATION  Cancel COMMAND if it runs longer than DURATION, e.g. 30s or 5m.")
+	fmt.Println("                       The command of -c is cancelled likewise, also when reloading")
 	fmt.Println("   --filter            Hide lines without a match")
+	fmt.Println("   --invert-filter     Hide lines with a match")
+	fmt.Println("   --exclude REGEX     Remove lines matching REGEX before matching PATTERN, can be")
+	fmt.Println("                       given multiple times")
+	fmt.Println("   --unique            Collapse all lines with the same match into the first one,")
+	fmt.Println("                       which shows the number of lines and can be expanded")
+	fmt.Println("   --unique-line       Collapse all identical lines into the first one")
+	fmt.Println("   --group             Show a header for each distinct match with its lines nested")
+	fmt.Println("                       below, which can be collapsed with [Left] and expanded with")
+	fmt.Println("                       [Right]. On a header, COMMAND is executed for the match")
 	fmt.Println("   --sort              Sort lines by their matches ignoring lines without a match")
 	fmt.Println("   --sort-rev          Reverse --sort")
+	fmt.Println("   --sort-mode MODE    Compare the matches when sorting as MODE, which is natural")
+	fmt.Println("                       (file2 before file10), version (1.9 before 1.10), human-size")
+	fmt.Println("                       (2K before 1G), time (9:05 before 10:00) or date. Keywords")
+	fmt.Println("                       like --time select a suitable mode")
+	fmt.Println("   -f, --file FILE     Read the lines of FILE instead of the pipe, can be given")
+	fmt.Println("                       multiple times. The lines are reloaded with [r]")
+	fmt.Println("   -c, --command CMD   Read the output of the shell command CMD instead of the pipe,")
+	fmt.Println("                       which is run again with [r]. Short options like -c must be")
+	fmt.Println("                       given before PATTERN as they belong to COMMAND otherwise")
+	fmt.Println("   -0, --null          Read records separated by NUL characters instead of lines,")
+	fmt.Println("                       e.g. of find -print0")
+	fmt.Println("   --delimiter STR     Read records separated by STR instead of lines, where escape")
+	fmt.Println("                       sequences like \\n are interpreted. In records of both, . in")
+	fmt.Println("                       patterns also matches line breaks")
+	fmt.Println("   --multi-line        Show records with line breaks over several rows, which are")
+	fmt.Println("                       selected as a unit. Otherwise, line breaks are shown as " + lineBreak)
+	fmt.Println("   --keep-empty        Keep blank lines as separators, which are skipped when moving")
+	fmt.Println("                       the cursor")
+	fmt.Println("   --header N          Pin the first N lines above the list, which are never matched,")
+	fmt.Println("                       sorted or filtered, e.g. the header of ps or df")
+	fmt.Println("   --columns           Read a table of columns separated by whitespace, e.g. of ps.")
+	fmt.Println("                       A header line with the names of the columns is detected and")
+	fmt.Println("                       pinned above the aligned lines")
+	fmt.Println("   --csv               Read a table of comma-separated values")
+	fmt.Println("   --tsv               Read a table of tab-separated values")
+	fmt.Println("   --column COLUMN     Match PATTERN in the field of COLUMN only, which is given by its")
+	fmt.Println("                       name in the header or its number starting at 1")
+	fmt.Println("   --jsonl             Read JSON Lines, where lines which are no valid JSON are dimmed")
+	fmt.Println("   --display TEMPLATE  Show each line of --jsonl as TEMPLATE with its fields inserted")
+	fmt.Println("                       by their paths, e.g. \"{.ts} {.level} {.msg}\"")
+	fmt.Println("   --match-field PATH  Match the value of the field PATH of --jsonl like .request_id")
+	fmt.Println("                       instead of PATTERN, which is appended to the line if it is")
+	fmt.Println("                       not shown by --display")
+	fmt.Println("   --theme THEME       Use the colors of THEME, which is default, light, dark,")
+	fmt.Println("                       high-contrast or a theme of the configuration file")
+	fmt.Println("   --keys PRESET       Use the key bindings of PRESET, which is default, vi or emacs")
+	fmt.Println("   --line-numbers      Show the number of each line in the input, which is inserted")
+	fmt.Println("                       into COMMAND for {n}")
+	fmt.Println("   --wrap              Wrap long lines over multiple rows instead of scrolling them")
+	fmt.Println("                       horizontally to the match")
+	fmt.Println("   --mouse             Select lines and matches with a click, execute COMMAND with a")
+	fmt.Println("                       double-click and scroll with the wheel. This disables the")
+	fmt.Println("                       text selection of the terminal")
+	fmt.Println("   --record            Record all executed commands in the history")
+	fmt.Println("                       $XDG_STATE_HOME/lisst/history")
+	fmt.Println("   --history           Display the recorded commands of previous sessions instead of")
+	fmt.Println("                       reading from the pipe and execute them again with [Enter]")
 	fmt.Println("   --help              Display this help")
 	fmt.Println("\nExamples:")
 	fmt.Println("\n   git log --oneline | " + os.Args[0] + " \"\\b[0-9a-f]{7,40}\\b\" git show")
@@ -104,6 +246,9 @@ func PrintHelp() {
 	fmt.Println("                       will recursively grep for \"func\" in all files, highlight all")
 	fmt.Println("                       file names, and open the text editor `vi <file name>` when")
 	fmt.Println("                       [Enter] is pressed.")
+	fmt.Println("\n   grep -rl TODO | " + os.Args[0] + " --shell --filename \"grep -n TODO {} | less\"")
+	fmt.Println("                       will display all files containing \"TODO\" and page through")
+	fmt.Println("                       the matching lines of the selected file using a shell pipe.")
 	fmt.Println("\n   squeue -u $USER | lisst --show-output \"^\\s*([0-9]{1,})\\b\" scontrol show job")
 	fmt.Println("                       will query SLURM for all running jobs of the current user,")
 	fmt.Println("                       highlight all job IDs, and show details of the selected job by")
@@ -111,55 +256,186 @@ func PrintHelp() {
 	fmt.Println("                       Note the additional flag `--show-output` to display the output")
 	fmt.Println("                       of `scontrol` instead of printing it to the terminal in the")
 	fmt.Println("                       background.")
+	fmt.Println("\n   ps aux | " + os.Args[0] + " --columns --column PID \"\\d+\" --confirm kill")
+	fmt.Println("                       will display all processes as a table and kill the process of")
+	fmt.Println("                       the selected line after confirmation.")
 }
 
-func readFromPipe() []string {
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		// There is no pipe
-		fmt.Fprintln(os.Stderr, "Missing input")
-		PrintHelp()
-		os.Exit(1)
+func PrintInfo(itemList *ItemList) string {
+	lines := []string{"Key bindings:", ""}
+	for _, line := range config.keymap.PrintHelp() {
+		lines = append(lines, "   " + line)
 	}
 
-	scanner := bufio.NewScanner(os.Stdin)
+	lines = append(lines, "", "PATTERN:", "")
+	if config.pattern == nil {
+		lines = append(lines, "   None")
+	} else if config.patternFuncInfo != "" {
+		lines = append(lines, fmt.Sprintf("   %s as %s", config.pattern, config.patternFuncInfo))
+	} else {
+		lines = append(lines, fmt.Sprintf("   %s", config.pattern))
+	}
 
-	input := []string{}
-	for scanner.Scan() {
-		// Read input line by line
-		line := scanner.Text()
-		if strings.TrimSpace(line) != "" {
-			input = append(input, line)
+	lines = append(lines, "", "COMMAND:", "")
+	if config.program == "" {
+		lines = append(lines, "   None")
+	} else {
+		lines = append(lines, "   " + strings.TrimSpace(config.program + " " + strings.Join(config.programArgs, " ")))
+		if config.shell {
+			lines = append(lines, "", "   Executed with $SHELL -c, where `{}` is replaced by the shell-quoted match")
+		} else {
+			lines = append(lines, "", "   `{}` is replaced by the match, otherwise the match is appended")
 		}
+		lines = append(lines, "   The match and the line are also available as LISST_MATCH, LISST_LINE, etc.")
 	}
 
-	if len(input) == 0 {
-		fmt.Fprintln(os.Stderr, "Empty input")
-		os.Exit(1)
+	sortModeInfo := "numbers or text"
+	if config.sortMode != "" {
+		sortModeInfo = config.sortMode
 	}
+	uniqueInfo := "off"
+	if config.unique != "" {
+		uniqueInfo = "by " + config.unique
+	}
+	timeoutInfo := "off"
+	if config.timeout > 0 {
+		timeoutInfo = config.timeout.String()
+	}
+	errorInfo := "show and continue"
+	if config.ignoreProgramError {
+		errorInfo = "ignore"
+	} else if config.exitOnProgramError {
+		errorInfo = "exit"
+	}
+
+	lines = append(lines, "", "Options:", "")
+	for _, file := range config.files {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "File", file))
+	}
+	if config.inputCommand != "" {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Input command", config.inputCommand))
+	}
+	if config.delimiter != "" {
+		lines = append(lines, fmt.Sprintf("   %-20s%q", "Delimiter", config.delimiter))
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Multi-line", onOff(config.multiLine)))
+	}
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Keep empty lines", onOff(config.keepEmpty)))
+	if config.header > 0 {
+		lines = append(lines, fmt.Sprintf("   %-20s%d", "Header lines", config.header))
+	}
+	if config.table != "" {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Table", config.table))
+	}
+	if config.column != "" {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Column", config.column))
+	}
+	if config.jsonl {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "JSON Lines", onOff(config.jsonl)))
+	}
+	if config.display != "" {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Display", config.display))
+	}
+	if config.matchField != "" {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Match field", config.matchField))
+	}
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Show", itemList.view))
+	for _, exclude := range config.exclude {
+		lines = append(lines, fmt.Sprintf("   %-20s%s", "Exclude", exclude))
+	}
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Unique", uniqueInfo))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Group", onOff(config.group)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Sort", itemList.order))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Sort mode", sortModeInfo))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Show output", onOff(config.showProgramOutput)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Confirm", onOff(config.confirm)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Dry run", onOff(config.dryRun)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Timeout", timeoutInfo))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Errors", errorInfo))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Record history", onOff(config.record)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Line numbers", onOff(config.lineNumbers)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Wrap", onOff(config.wrap)))
+	lines = append(lines, fmt.Sprintf("   %-20s%s", "Mouse", onOff(config.mouse)))
+	return strings.Join(lines, "\n")
+}
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
 
-	err := scanner.Err()
+func readFromInput() []string {
+	var input []string
+	var err error
+	if len(config.files) > 0 || config.inputCommand != "" {
+		// Like reloading, Ctrl-C and --timeout stop the command of -c
+		ctx, cancel := timeoutContext()
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		input, inputSources, err = readSources(ctx)
+		stop()
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New(FormatExitStatus(statusTimeout))
+		} else if errors.Is(err, context.Canceled) {
+			err = errors.New(FormatExitStatus(statusCancelled))
+		}
+	} else {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeCharDevice) != 0 {
+			// There is no pipe
+			fmt.Fprintln(os.Stderr, "Missing input")
+			PrintHelp()
+			os.Exit(1)
+		}
+		input, err = readInput(os.Stdin)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error reading input:", err)
+		if len(input) == 0 {
+			os.Exit(1)
+		}
+		// Keep the lines read so far and show the error in the status bar
+		inputError = fmt.Errorf("Error reading input after %d lines: %w", len(input), err)
+	}
+
+	if isEmpty(input) {
+		fmt.Fprintln(os.Stderr, "Empty input")
+		os.Exit(1)
+	}
+	return input
+}
+
+func readFromHistory() []string {
+	input, err := ReadHistory()
+	if err != nil || len(input) == 0 {
+		fmt.Fprintln(os.Stderr, "Empty history, use --record to record executed commands")
 		os.Exit(1)
 	}
 	return input
 }
 
-func run(itemList *ItemList, selectedIndex int, programExecuted string, programOutput string, exitStatus string) {
+func run(itemList *ItemList, selectedIndex int, result *Result) {
 	ui := initUi()
 	ui.fillList(itemList, selectedIndex)
-	ui.pageList.setStatus(exitStatus)
+	ui.pageList.setStatus(result)
+	ui.pageList.revealMatch()
 
-	if programExecuted != "" && config.showProgramOutput {
-		ui.setText(programExecuted, programOutput)
+	if result != nil && result.command != "" && config.showProgramOutput {
+		ui.setText(result.command, result.output)
 	}
 
+	ui.runApp()
+}
+
+// Runs the list until it is left, also when tview stops it on Ctrl-C
+func (ui *Ui) runApp() {
 	err := ui.app.Run()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	ui.quit()
 }
 
 func initUi() *Ui {
@@ -169,50 +445,67 @@ func initUi() *Ui {
 	}
 
 	ui.app = tview.NewApplication()
+	ui.app.EnableMouse(config.mouse)
 	ui.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		// Keys for quitting the program
-		if event.Rune() == 'q' || event.Key() == tcell.KeyEsc {
-			if ui.pageTextVisible {
+		if event.Key() == tcell.KeyCtrlC && ui.cancelReload != nil {
+			// Cancel reloading instead of quitting
+			ui.cancelReload()
+			return nil
+		} else if ui.modalVisible {
+			// Keys for the confirmation prompt
+			return ui.confirmKey(event)
+		} else if ui.helpVisible {
+			// Keys for closing the help
+			action, _ := config.keymap.Lookup([]string{KeyName(event)})
+			if action == "quit" || action == "help" {
+				ui.closeModal()
+				return nil
+			}
+			return event
+		} else if ui.pageTextVisible {
+			// Keys for returning to the list
+			action, _ := config.keymap.Lookup([]string{KeyName(event)})
+			if action == "quit" {
 				ui.app.SetRoot(ui.pageList.flex, true)
 				ui.pageTextVisible = false
-			} else {
-				ui.app.Stop()
-				os.Exit(0)
+				return nil
 			}
-		} else if event.Rune() == 'n' && !ui.pageTextVisible {
-			ui.pageList.jumpToMatch(true)
-		} else if event.Rune() == 'N' && !ui.pageTextVisible {
-			ui.pageList.jumpToMatch(false)
+			return event
 		}
-		return event
+		return ui.handleKey(event)
 	})
 
 	// Container for the list and its status bar
 	ui.pageList.flex = tview.NewFlex()
 	ui.pageList.flex.SetDirection(tview.FlexRow)
 	ui.pageTextVisible = false
+	ui.modalVisible = false
+	ui.helpVisible = false
 
 	// List for the matches
-	ui.pageList.list = tview.NewList()
+	ui.pageList.list = NewWrapList()
+	ui.pageList.list.wrap = config.wrap
+	ui.pageList.list.multiLine = config.multiLine
 	ui.pageList.list.ShowSecondaryText(false)
 	ui.pageList.list.SetWrapAround(false)
 	ui.pageList.list.SetHighlightFullLine(true)
-	style := tcell.StyleDefault
-	style = style.Reverse(true)
-	ui.pageList.list.SetSelectedStyle(style)
+	ui.pageList.list.SetSelectedStyle(ParseStyle(theme.selected))
+
+	// Lines pinned above the list, which are sized when filling the list
+	ui.pageList.header = ui.pageList.list.NewHeader()
+	ui.pageList.flex.AddItem(ui.pageList.header, 0, 0, false)
 	ui.pageList.flex.AddItem(ui.pageList.list, 0, 1, true)
 
 	// Invoked when a line is highlighted
 	ui.pageList.list.SetChangedFunc(ui.pageList.lineSelected)
-	if config.program != "" {
-		// Invoked when enter is pressed on a line
-		ui.pageList.list.SetSelectedFunc(ui.lineClicked)
-	}
+	ui.pageList.list.SetMouseCapture(ui.handleMouse)
 
 	// Status line at the bottom
 	ui.pageList.status = tview.NewTextView()
 	ui.pageList.status.SetScrollable(false)
 	ui.pageList.status.SetWrap(false)
+	ui.pageList.status.SetDynamicColors(true)
+	setTextViewStyle(ui.pageList.status, theme.status)
 	ui.pageList.flex.AddItem(ui.pageList.status, 2, 1, false)
 
 	// Container for the text and its status bar
@@ -223,6 +516,7 @@ func initUi() *Ui {
 	ui.pageText.text = tview.NewTextView()
 	ui.pageText.text.SetScrollable(true)
 	ui.pageText.text.SetWrap(false)
+	setTextViewStyle(ui.pageText.text, theme.output)
 	ui.pageText.flex.AddItem(ui.pageText.text, 0, 1, true)
 
 	// Status line at the bottom
@@ -230,44 +524,79 @@ func initUi() *Ui {
 	ui.pageText.status.SetScrollable(false)
 	ui.pageText.status.SetWrap(false)
 	ui.pageText.status.SetRegions(true)
+	setTextViewStyle(ui.pageText.status, theme.status)
 	ui.pageText.flex.AddItem(ui.pageText.status, 1, 1, false)
 
 	ui.app.SetRoot(ui.pageList.flex, true)
 	return ui
 }
 
+func setTextViewStyle(textView *tview.TextView, style string) {
+	if style == "" {
+		return
+	}
+	textView.SetTextStyle(ParseStyle(style))
+	_, background, _ := ParseStyle(style).Decompose()
+	textView.SetBackgroundColor(background)
+}
+
 func (ui *Ui) fillList(itemList *ItemList, selectedIndex int) {
 	ui.pageList.itemList = itemList
+	pinned := itemList.DisplayPinned()
+	for _, line := range pinned {
+		ui.pageList.header.AddItem(line, "", 0, nil)
+	}
+	ui.pageList.flex.ResizeItem(ui.pageList.header, len(pinned), 0)
 
-	for _, item := range ui.pageList.itemList.items {
+	gutter := itemList.Gutter()
+	for i := range ui.pageList.itemList.items {
 		// Build the list
-		ui.pageList.list.AddItem(item.display, "", 0, nil)
+		ui.pageList.list.AddItem(itemList.Display(i, gutter), "", 0, nil)
 	}
 
 	if selectedIndex < ui.pageList.list.GetItemCount() {
 		// Set the cursor to the previous line if possible
-		ui.pageList.list.SetCurrentItem(selectedIndex)
+		ui.pageList.list.SetCurrentItem(itemList.Selectable(selectedIndex, 1))
 	}
 
 	if config.test {
 		// Used for the tests
-		ui.app.Stop()
-		if config.program != "" && ui.pageList.list.GetItemCount() > 0 {
-			_, output, _ := ui.pageList.itemList.Get(0).RunCommand()
-			if output != "" {
-				fmt.Println(output)
+		if config.program != "" && config.dryRun {
+			ui.dryRunCommands = append(ui.dryRunCommands, ui.pageList.itemList.Get(0).PrintCommand())
+		} else if config.program != "" && ui.pageList.list.GetItemCount() > 0 {
+			result := ui.pageList.itemList.Get(0).RunCommand(0)
+			if result.output != "" {
+				fmt.Println(result.output)
+			}
+			if result.message != "" {
+				fmt.Fprintln(os.Stderr, result.message)
 			}
 		} else {
 			itemList.Print()
 		}
-		os.Exit(0)
+		ui.quit()
 	}
 }
 
-func (pageList *PageList) setStatus(exitStatus string) {
-	info := "\n"
+func (pageList *PageList) setStatus(result *Result) {
+	info := ""
 	space := "     "
 
+	errorInfo := ""
+	if pageList.reloading {
+		errorInfo = tview.Escape("Reloading the input, press [Ctrl-C] to cancel")
+	} else if inputError != nil {
+		errorInfo = theme.Apply(theme.error, tview.Escape(inputError.Error()))
+	}
+	if result != nil && result.Failed() && !config.ignoreProgramError {
+		// Show why the command failed in the first line
+		errorInfo = "Error: " + FormatExitStatus(result.exitStatus)
+		if result.message != "" {
+			errorInfo += ": " + result.message
+		}
+		errorInfo = theme.Apply(theme.error, tview.Escape(errorInfo))
+	}
+
 	if config.pattern != nil {
 		numMatches := pageList.itemList.NumMatches()
 		if numMatches > 1 {
@@ -285,17 +614,174 @@ func (pageList *PageList) setStatus(exitStatus string) {
 
 	index := pageList.list.GetCurrentItem()
 	info += fmt.Sprintf("%sLine %d of %d", space, index + 1, pageList.list.GetItemCount())
+	item := pageList.itemList.Get(index)
+	if item.header {
+		if len(item.children) == 1 {
+			info += " (group of 1 line)"
+		} else {
+			info += fmt.Sprintf(" (group of %d lines)", len(item.children))
+		}
+	} else if item.source != "" {
+		info += fmt.Sprintf(" (%s:%d)", item.source, item.LineNumber())
+	} else if item.number != index + 1 {
+		// The lines have been filtered, sorted or grouped
+		info += fmt.Sprintf(" (input line %d)", item.LineNumber())
+	}
+	if !item.header && len(item.duplicates) > 0 {
+		info += fmt.Sprintf(" (%d occurrences)", len(item.duplicates) + 1)
+	}
+	if pageList.itemList.view != viewAll {
+		info += fmt.Sprintf("%sShowing %s", space, pageList.itemList.view)
+	}
+	if pageList.itemList.order != sortOriginal {
+		info += fmt.Sprintf("%sSorted by %s", space, pageList.itemList.order)
+	}
 	if config.program != "" && pageList.itemList.Get(index).HasMatch() {
-		info += space + pageList.itemList.Get(index).PrintCommand()
-		if exitStatus != "" {
-			info += space + "Exit=" + exitStatus
+		item := pageList.itemList.Get(index)
+		info += space + escapeBinary(item.PrintCommand())
+		if result != nil {
+			info += space + FormatExitStatus(result.exitStatus)
+		} else if item.Executed() {
+			info += space + "Executed at " + item.executed.Format(time.TimeOnly) + " with " + FormatExitStatus(item.exitStatus)
 		}
 	}
 
-	pageList.status.SetText(info)
+	pageList.status.SetText(errorInfo + "\n" + tview.Escape(info))
+}
+
+func (pageList *PageList) move(offset int) {
+	index := pageList.list.GetCurrentItem() + offset
+	index = max(0, min(index, pageList.list.GetItemCount() - 1))
+	index = pageList.itemList.Selectable(index, offset)
+	pageList.list.SetCurrentItem(index)
+	pageList.setStatus(nil)
+	pageList.revealMatch()
+}
+
+// Returns the index of the line at the given screen position or -1
+func (pageList *PageList) indexAtPoint(x int, y int) int {
+	rectX, rectY, width, height := pageList.list.GetInnerRect()
+	if x < rectX || x >= rectX + width || y < rectY || y >= rectY + height {
+		return -1
+	}
+	return pageList.list.IndexAtRow(y - rectY)
+}
+
+func (pageList *PageList) selectMatchAt(index int, x int, y int) {
+	rectX, rectY, _, _ := pageList.list.GetInnerRect()
+	column := pageList.list.ColumnAt(index, y - rectY, x - rectX)
+
+	// Skip the markers and line numbers in front of the line
+	gutter := pageList.itemList.Gutter()
+	column -= pageList.itemList.GutterWidth(index, gutter)
+
+	item := pageList.itemList.Get(index)
+	if column >= 0 && item.SelectMatchAt(column) {
+		pageList.list.SetItemText(index, pageList.itemList.Display(index, gutter), "")
+	}
+}
+
+func (pageList *PageList) sort(order string) {
+	number := pageList.itemList.Get(pageList.list.GetCurrentItem()).number
+	pageList.itemList.SortBy(order)
+	pageList.update(number)
+}
+
+func (pageList *PageList) filter(view string) {
+	// Keep the cursor on the same line if it is still shown
+	index := pageList.list.GetCurrentItem()
+	number := pageList.itemList.Get(index).number
+	if pageList.itemList.SetView(view) != nil {
+		return
+	}
+	if pageList.itemList.Find(number) < 0 {
+		number = pageList.itemList.Get(min(index, len(pageList.itemList.items) - 1)).number
+	}
+	pageList.update(number)
+}
+
+func (pageList *PageList) toggle() {
+	number := pageList.itemList.Get(pageList.list.GetCurrentItem()).number
+	if pageList.itemList.Toggle(pageList.list.GetCurrentItem()) {
+		pageList.update(number)
+	}
+}
+
+// Collapses the group of the current line and returns whether there is any
+func (pageList *PageList) collapse() bool {
+	index := pageList.list.GetCurrentItem()
+	item := pageList.itemList.Get(index)
+	_, horizontal := pageList.list.GetOffset()
+	if !pageList.itemList.grouped || horizontal > 0 || (!item.header && !item.child) {
+		return false
+	}
+
+	if item.child {
+		// Move to the header first
+		pageList.list.SetCurrentItem(pageList.itemList.Header(index))
+		pageList.setStatus(nil)
+		pageList.revealMatch()
+	} else if item.expanded {
+		pageList.toggle()
+	}
+	return true
+}
+
+// Expands the group of the current line and returns whether it has been collapsed
+func (pageList *PageList) expand() bool {
+	item := pageList.itemList.Get(pageList.list.GetCurrentItem())
+	if !pageList.itemList.grouped || !item.header || item.expanded {
+		return false
+	}
+	pageList.toggle()
+	return true
+}
+
+// Fills the list again after the lines have changed and keeps the cursor on the line of the given number
+func (pageList *PageList) update(number int) {
+	pageList.list.Clear()
+	gutter := pageList.itemList.Gutter()
+	for i := range pageList.itemList.items {
+		pageList.list.AddItem(pageList.itemList.Display(i, gutter), "", 0, nil)
+	}
+	pageList.list.SetCurrentItem(pageList.itemList.Find(number))
+	pageList.setStatus(nil)
+	pageList.revealMatch()
+}
+
+func (pageList *PageList) scroll(offset int) {
+	if pageList.list.Rows() {
+		return
+	}
+	items, horizontal := pageList.list.GetOffset()
+	pageList.list.SetOffset(items, max(0, horizontal + offset))
+}
+
+// Scrolls horizontally until the match of the current line is visible
+func (pageList *PageList) revealMatch() {
+	if pageList.list.Rows() || pageList.list.GetItemCount() == 0 {
+		return
+	}
+	item := pageList.itemList.Get(pageList.list.GetCurrentItem())
+	if !item.HasMatch() {
+		return
+	}
+
+	// Skip the markers and line numbers in front of the line
+	gutter := pageList.itemList.GutterWidth(pageList.list.GetCurrentItem(), pageList.itemList.Gutter())
+	pageList.list.Reveal(item.matchStart + gutter, item.matchEnd + gutter)
+}
+
+func (pageList *PageList) pageHeight() int {
+	_, _, _, height := pageList.list.GetInnerRect()
+	return max(height, 1)
 }
 
 func (pageList *PageList) jumpToMatch(forward bool) {
+	pageList.jumpTo(forward, (*Item).HasMatch)
+}
+
+func (pageList *PageList) jumpTo(forward bool, condition func(*Item) bool) {
 	count := pageList.list.GetItemCount()
 	if count < 2 {
 		return
@@ -305,14 +791,14 @@ func (pageList *PageList) jumpToMatch(forward bool) {
 
 	if forward && index < count - 1 {
 		for i := index + 1; i < count; i++ {
-			if pageList.itemList.Get(i).HasMatch() {
+			if !pageList.itemList.Get(i).separator && condition(pageList.itemList.Get(i)) {
 				index = i
 				break
 			}
 		}
 	} else if !forward && index > 0 {
 		for i := index - 1; i >= 0; i-- {
-			if pageList.itemList.Get(i).HasMatch() {
+			if !pageList.itemList.Get(i).separator && condition(pageList.itemList.Get(i)) {
 				index = i
 				break
 			}
@@ -320,7 +806,8 @@ func (pageList *PageList) jumpToMatch(forward bool) {
 	}
 
 	pageList.list.SetCurrentItem(index)
-	pageList.setStatus("")
+	pageList.setStatus(nil)
+	pageList.revealMatch()
 }
 
 func (ui *Ui) setText(programExecuted string, programOutput string) {
@@ -339,19 +826,293 @@ func (ui *Ui) setText(programExecuted string, programOutput string) {
 
 // Signature of this function must not be changed
 func (pageList *PageList) lineSelected(index int, _ string, _ string, _ rune) {
-	pageList.setStatus("")
+	pageList.setStatus(nil)
+	pageList.revealMatch()
 }
 
-// Signature of this function must not be changed
-func (ui *Ui) lineClicked(index int, _ string, _ string, _ rune) {
+func (ui *Ui) handleMouse(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+	if ui.modalVisible || ui.helpVisible {
+		// Clicks beside the overlay must not reach the list
+		return action, nil
+	}
+
+	switch action {
+	case tview.MouseLeftClick:
+		x, y := event.Position()
+		index := ui.pageList.indexAtPoint(x, y)
+		if index < 0 || ui.pageList.itemList.Get(index).separator {
+			return action, nil
+		}
+		ui.pageList.selectMatchAt(index, x, y)
+		ui.pageList.list.SetCurrentItem(index)
+		ui.pageList.setStatus(nil)
+		return action, ui.pageList.list.ItemEvent(index, event)
+	case tview.MouseLeftDoubleClick:
+		index := ui.pageList.indexAtPoint(event.Position())
+		if index < 0 {
+			return action, nil
+		}
+		if index == ui.pageList.list.GetCurrentItem() {
+			ui.executeLine(index, config.confirm)
+		}
+		// Handled like a single click by the list to redraw it
+		return tview.MouseLeftClick, ui.pageList.list.ItemEvent(index, event)
+	}
+	return action, event
+}
+
+func (ui *Ui) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	name := KeyName(event)
+
+	if config.keymap.counts && len(ui.keySequence) == 0 && len(name) == 1 && name[0] >= '0' && name[0] <= '9' &&
+		(ui.keyCount > 0 || name != "0") {
+		// Number to repeat the following action
+		ui.keyCount = ui.keyCount * 10 + int(name[0] - '0')
+		return nil
+	}
+
+	ui.keySequence = append(ui.keySequence, name)
+	action, pending := config.keymap.Lookup(ui.keySequence)
+	if pending {
+		// Wait for the next key of the sequence
+		return nil
+	}
+
+	count := ui.keyCount
+	ui.keySequence = nil
+	ui.keyCount = 0
+	if action == "" {
+		// Any other key is handled by the list itself
+		return event
+	}
+
+	if count < 1 || !findAction(action).repeatable {
+		count = 1
+	}
+	for i := 0; i < count; i++ {
+		ui.perform(action)
+	}
+	return nil
+}
+
+func (ui *Ui) perform(action string) {
+	pageList := ui.pageList
+	switch action {
+	case "quit":
+		ui.quit()
+	case "down":
+		pageList.move(1)
+	case "up":
+		pageList.move(-1)
+	case "half-page-down":
+		pageList.move(pageList.pageHeight() / 2)
+	case "half-page-up":
+		pageList.move(-pageList.pageHeight() / 2)
+	case "page-down":
+		pageList.move(pageList.pageHeight())
+	case "page-up":
+		pageList.move(-pageList.pageHeight())
+	case "top":
+		pageList.move(-pageList.list.GetItemCount())
+	case "bottom":
+		pageList.move(pageList.list.GetItemCount())
+	case "next-match":
+		pageList.jumpToMatch(true)
+	case "prev-match":
+		pageList.jumpToMatch(false)
+	case "next-unvisited":
+		pageList.jumpTo(true, (*Item).Unvisited)
+	case "prev-unvisited":
+		pageList.jumpTo(false, (*Item).Unvisited)
+	case "scroll-left":
+		if !pageList.collapse() {
+			pageList.scroll(-scrollStep)
+		}
+	case "scroll-right":
+		if !pageList.expand() {
+			pageList.scroll(scrollStep)
+		}
+	case "wrap":
+		config.wrap = !config.wrap
+		pageList.list.wrap = config.wrap
+		pageList.revealMatch()
+	case "sort":
+		pageList.sort(pageList.itemList.NextOrder())
+	case "expand":
+		pageList.toggle()
+	case "filter":
+		pageList.filter(pageList.itemList.NextView())
+	case "reload":
+		ui.reload()
+	case "history":
+		ui.setText("Commands executed in this session", PrintHistory())
+	case "help":
+		ui.showHelp()
+	case "execute":
+		ui.executeLine(pageList.list.GetCurrentItem(), config.confirm)
+	case "execute-confirm":
+		ui.executeLine(pageList.list.GetCurrentItem(), true)
+	}
+}
+
+// Reads the files of -f or runs the command of -c again and keeps the previous lines on errors
+func (ui *Ui) reload() {
+	if len(config.files) == 0 && config.inputCommand == "" || ui.cancelReload != nil {
+		return
+	}
+
+	// Read in the background, so a slow command of -c neither blocks the list nor prevents cancelling it
+	ctx, cancel := timeoutContext()
+	ui.cancelReload = cancel
+	ui.pageList.reloading = true
+	ui.pageList.setStatus(nil)
+	go func() {
+		input, sources, err := readSources(ctx)
+		ui.app.QueueUpdateDraw(func() {
+			cancel()
+			ui.cancelReload = nil
+			ui.pageList.reloading = false
+			ui.fillReloaded(input, sources, err)
+		})
+	}()
+}
+
+// Shows the reloaded input, or keeps the current lines if reloading has been stopped
+func (ui *Ui) fillReloaded(input []string, sources []inputSource, err error) {
+	itemList := ui.pageList.itemList
+	inputError = nil
+	if errors.Is(err, context.DeadlineExceeded) {
+		inputError = errors.New("Reloading stopped: " + FormatExitStatus(statusTimeout))
+	} else if errors.Is(err, context.Canceled) {
+		inputError = errors.New("Reloading stopped: " + FormatExitStatus(statusCancelled))
+	} else {
+		if err != nil {
+			inputError = fmt.Errorf("Error reading input after %d lines: %w", len(input), err)
+		}
+		if isEmpty(input) {
+			if inputError == nil {
+				inputError = errors.New("Empty input")
+			}
+		} else if reloaded, err := buildItemList(input); err != nil {
+			inputError = err
+		} else {
+			itemList = reloaded
+			inputSources = sources
+		}
+	}
+
+	index := min(ui.pageList.list.GetCurrentItem(), len(itemList.items) - 1)
+	ui.pageList.header.Clear()
+	ui.pageList.list.Clear()
+	ui.fillList(itemList, index)
+	ui.pageList.setStatus(nil)
+	ui.pageList.revealMatch()
+}
+
+func (ui *Ui) executeLine(index int, confirm bool) {
 	item := ui.pageList.itemList.Get(index)
-	if item.HasMatch() {
-		ui.app.Stop()
+	if config.program == "" || !item.HasMatch() {
+		return
+	}
 
-		// Run the program and fetch the output if it is not writing to stdout
-		program, output, exitStatus := item.RunCommand()
+	if config.dryRun {
+		// Only record the command to print it on exit
+		ui.dryRunCommands = append(ui.dryRunCommands, item.PrintCommand())
+		ui.pageList.setStatus(&Result{exitStatus: statusDryRun})
+	} else if confirm {
+		ui.confirm(index)
+	} else {
+		ui.execute(index)
+	}
+}
+
+func (ui *Ui) execute(index int) {
+	ui.stopReload()
+	ui.app.Stop()
+
+	// Run the program and fetch the output if it is not writing to stdout
+	result := ui.pageList.itemList.Get(index).RunCommand(index)
+
+	// Restart the list view
+	run(ui.pageList.itemList, index, result)
+}
+
+func (ui *Ui) confirm(index int) {
+	modal := tview.NewModal()
+	modal.SetText("Execute the following command?\n\n" + tview.Escape(escapeBinary(ui.pageList.itemList.Get(index).PrintCommand())) + "\n\n" + tview.Escape("[y] Yes   [n] No"))
+
+	// No buttons, so only an explicit [y] confirms and not a stray [Enter]
+
+	// Display the prompt on top of the list
+	pages := tview.NewPages()
+	pages.AddPage("list", ui.pageList.flex, true, true)
+	pages.AddPage("confirm", modal, true, true)
+	ui.app.SetRoot(pages, true)
+	ui.modalVisible = true
+}
+
+func (ui *Ui) confirmKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Rune() == 'y' {
+		ui.closeModal()
+		ui.execute(ui.pageList.list.GetCurrentItem())
+		return nil
+	} else if event.Rune() == 'n' || event.Rune() == 'q' || event.Key() == tcell.KeyEsc {
+		ui.closeModal()
+		return nil
+	}
+	return event
+}
+
+func (ui *Ui) showHelp() {
+	text := tview.NewTextView()
+	text.SetText(PrintInfo(ui.pageList.itemList))
+	text.SetScrollable(true)
+	text.SetWrap(false)
+	text.SetBorder(true)
+	text.SetTitle(" Help ")
+
+	// Center the help with a margin around it
+	column := tview.NewFlex()
+	column.SetDirection(tview.FlexRow)
+	column.AddItem(nil, 0, 1, false)
+	column.AddItem(text, 0, 8, true)
+	column.AddItem(nil, 0, 1, false)
+	row := tview.NewFlex()
+	row.AddItem(nil, 0, 1, false)
+	row.AddItem(column, 0, 8, true)
+	row.AddItem(nil, 0, 1, false)
+
+	// Display the help on top of the list
+	pages := tview.NewPages()
+	pages.AddPage("list", ui.pageList.flex, true, true)
+	pages.AddPage("help", row, true, true)
+	ui.app.SetRoot(pages, true)
+	ui.app.SetFocus(text)
+	ui.helpVisible = true
+}
+
+func (ui *Ui) closeModal() {
+	ui.app.SetRoot(ui.pageList.flex, true)
+	ui.modalVisible = false
+	ui.helpVisible = false
+}
+
+// Every exit from the list ends here, with the exit code of the executed commands
+func (ui *Ui) quit() {
+	ui.stopReload()
+	ui.app.Stop()
+
+	// Print the commands that would have been executed
+	for _, command := range ui.dryRunCommands {
+		fmt.Println(command)
+	}
+	os.Exit(sessionExitCode)
+}
 
-		// Restart the list view
-		run(ui.pageList.itemList, index, program, output, exitStatus)
+// Stops reloading the input before the list is left
+func (ui *Ui) stopReload() {
+	if ui.cancelReload != nil {
+		ui.cancelReload()
+		ui.cancelReload = nil
 	}
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPrintInfo(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9]+")
+	config.program = "kill"
+	config.programArgs = []string{"-9"}
+	config.timeout = 5 * time.Second
+	config.confirm = true
+	keymap, err := NewKeymap("vi", map[string][]string{"quit": {"Ctrl-Q"}})
+	if err != nil {
+		t.Fatal("Incorrect keymap", err)
+	}
+	config.keymap = keymap
+	info := PrintInfo(NewItemList([]string{"1", "2"}))
+
+	lines := strings.Split(info, "\n")
+	for _, line := range keymap.PrintHelp() {
+		if !strings.Contains(info, "\n   " + line + "\n") {
+			t.Error("Missing key binding", line)
+		}
+	}
+	if !strings.Contains(info, "[Ctrl-Q]") || strings.Contains(info, "[q] or [Esc]") {
+		t.Error("Incorrect overridden key binding")
+	}
+	if !strings.Contains(info, "\nPATTERN:\n\n   [0-9]+\n") {
+		t.Error("Incorrect pattern")
+	}
+	if !strings.Contains(info, "\nCOMMAND:\n\n   kill -9\n") {
+		t.Error("Incorrect command")
+	}
+	for _, option := range []string{
+		"   Confirm             on",
+		"   Dry run             off",
+		"   Timeout             5s",
+		"   Errors              show and continue",
+		"   Mouse               off",
+	} {
+		if !slices.Contains(lines, option) {
+			t.Error("Missing option line", option)
+		}
+	}
+}
+
+func TestQuitWithCtrlC(t *testing.T) {
+	if os.Getenv("LISST_TEST_QUIT") == "" {
+		// Quitting exits the process, so the test runs in a child process
+		cmd := exec.Command(os.Args[0], "-test.run=^TestQuitWithCtrlC$")
+		cmd.Env = append(os.Environ(), "LISST_TEST_QUIT=1")
+		err := cmd.Run()
+		var exitError *exec.ExitError
+		if !errors.As(err, &exitError) || exitError.ExitCode() != 3 {
+			t.Error("Incorrect exit code after Ctrl-C", err)
+		}
+		return
+	}
+
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[0-9]+")
+	config.program = "sh"
+	config.programArgs = []string{"-c", "exit 3"}
+	config.keymap, _ = NewKeymap("default", nil)
+	itemList := NewItemList([]string{"1", "2"})
+	itemList.Get(0).RunCommand(0)
+
+	screen := tcell.NewSimulationScreen("")
+	ui := initUi()
+	ui.app.SetScreen(screen)
+	ui.fillList(itemList, 0)
+	screen.InjectKey(tcell.KeyCtrlC, 0, tcell.ModNone)
+	ui.runApp()
+}
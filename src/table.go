@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"github.com/rivo/tview"
+)
+
+const (
+	tableColumns = "columns"
+	tableCsv = "csv"
+	tableTsv = "tsv"
+)
+
+// Separator of the columns of --columns, where columns are aligned with several spaces
+var reColumnSeparator = regexp.MustCompile("\\s{2,}")
+var reFieldSeparator = regexp.MustCompile("\\s+")
+
+func NewTableItemList(input []string) (*ItemList, error) {
+	cleaned := []string{}
+	for _, line := range input {
+		line = reAnsiColorCodes.ReplaceAllString(line, "")
+		if config.table == tableColumns {
+			// Only aligned columns may be indented, while leading separators of --csv and --tsv are empty fields
+			line = strings.TrimSpace(line)
+		}
+		cleaned = append(cleaned, line)
+	}
+	pinned, items := splitInput(cleaned)
+	// Blank lines of --keep-empty do not fit into a table
+	items = slices.DeleteFunc(items, func(item Item) bool { return item.separator })
+	lines := []string{}
+	for _, item := range items {
+		lines = append(lines, item.line)
+	}
+
+	if len(pinned) > 0 {
+		// The last line of --header names the columns
+		lines = append([]string{pinned[len(pinned) - 1]}, lines...)
+		items = append([]Item{{}}, items...)
+		pinned = pinned[:len(pinned) - 1]
+	}
+	list := newItemList(pinned)
+	if len(lines) == 0 {
+		return list, nil
+	}
+
+	rows := splitTable(lines)
+	var header []string
+	if config.header > 0 || len(rows) > 1 && isHeader(rows[0], rows[1]) {
+		header = rows[0]
+		rows = rows[1:]
+		items = items[1:]
+	}
+
+	column := -1
+	if config.column != "" {
+		var err error
+		column, err = findColumn(header, rows, config.column)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	widths := columnWidths(append([][]string{header}, rows...))
+	for i, fields := range rows {
+		text, spans := formatRow(fields, widths)
+		item := items[i]
+		item.fields = fields
+		item.fieldNames = header
+		if column >= 0 {
+			// Lines without the column have no match
+			item.column = true
+			item.columnStart = len(text)
+			item.columnEnd = len(text)
+			if column < len(spans) {
+				item.columnStart = spans[column][0]
+				item.columnEnd = spans[column][1]
+			}
+		}
+		item.process(text)
+		list.items = append(list.items, item)
+	}
+
+	if header != nil {
+		text, _ := formatRow(header, widths)
+		list.pinned = append(list.pinned, tview.Escape(escapeBinary(text)))
+	}
+	return list, nil
+}
+
+// Splits all lines into fields according to --columns, --csv or --tsv
+func splitTable(lines []string) [][]string {
+	rows := [][]string{}
+	switch config.table {
+	case tableCsv:
+		for _, line := range lines {
+			reader := csv.NewReader(strings.NewReader(line))
+			reader.LazyQuotes = true
+			fields, err := reader.Read()
+			if err != nil {
+				fields = []string{line}
+			}
+			rows = append(rows, fields)
+		}
+	case tableTsv:
+		for _, line := range lines {
+			rows = append(rows, strings.Split(line, "\t"))
+		}
+	default:
+		// Columns are separated by several spaces if this gives the same number of columns in all lines,
+		// otherwise by any space, where the last column takes the rest of the line, e.g. the command of ps
+		separator := reColumnSeparator
+		count := len(separator.Split(lines[0], -1))
+		for _, line := range lines {
+			if count < 2 || len(separator.Split(line, -1)) != count {
+				separator = reFieldSeparator
+				count = len(separator.Split(lines[0], -1))
+				break
+			}
+		}
+		for _, line := range lines {
+			rows = append(rows, separator.Split(line, count))
+		}
+	}
+	return rows
+}
+
+// Returns whether the first row names the columns of the following rows
+func isHeader(first []string, second []string) bool {
+	upperCase := true
+	for _, field := range first {
+		if field == "" || isNumber(field) {
+			return false
+		}
+		if strings.IndexFunc(field, unicode.IsLower) >= 0 {
+			upperCase = false
+		}
+	}
+	if upperCase {
+		return true
+	}
+
+	// Names of columns with numbers, e.g. "name,age"
+	for i, field := range second {
+		if i < len(first) && isNumber(field) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// Returns the index of the column of the given name or number starting at 1
+func findColumn(header []string, rows [][]string, name string) (int, error) {
+	for i, field := range header {
+		if strings.EqualFold(field, name) {
+			return i, nil
+		}
+	}
+
+	count := len(header)
+	for _, row := range rows {
+		count = max(count, len(row))
+	}
+	number, err := strconv.Atoi(name)
+	if err == nil && number >= 1 && number <= count {
+		return number - 1, nil
+	}
+	return -1, errors.New("Invalid column " + name)
+}
+
+func columnWidths(rows [][]string) []int {
+	widths := []int{}
+	for _, row := range rows {
+		for i, field := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], displayWidth(field))
+		}
+	}
+	return widths
+}
+
+// Aligns the fields in columns and returns the byte positions of each field
+func formatRow(fields []string, widths []int) (string, [][2]int) {
+	var builder strings.Builder
+	spans := [][2]int{}
+	for i, field := range fields {
+		if i > 0 {
+			builder.WriteString("  ")
+		}
+		start := builder.Len()
+		builder.WriteString(field)
+		spans = append(spans, [2]int{start, builder.Len()})
+		if i < len(fields) - 1 {
+			builder.WriteString(strings.Repeat(" ", max(widths[i] - displayWidth(field), 0)))
+		}
+	}
+	return builder.String(), spans
+}
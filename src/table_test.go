@@ -0,0 +1,120 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSplitTable(t *testing.T) {
+	config = &Config{}
+	config.table = tableCsv
+	rows := splitTable([]string{"name,city", "bob,\"New York, NY\""})
+	if len(rows) != 2 || len(rows[1]) != 2 || rows[1][1] != "New York, NY" {
+		t.Error("Incorrect fields of CSV", rows)
+	}
+
+	config.table = tableColumns
+	rows = splitTable([]string{"NAME    STATUS   AGE", "web-1   Running  5d", "db-1    Pending  12h"})
+	if len(rows[1]) != 3 || rows[1][1] != "Running" {
+		t.Error("Incorrect fields of columns separated by several spaces", rows)
+	}
+
+	// The last column takes the rest of the line
+	rows = splitTable([]string{"USER PID COMMAND", "root 1 sleep 5"})
+	if len(rows[1]) != 3 || rows[1][2] != "sleep 5" {
+		t.Error("Incorrect fields of columns separated by single spaces", rows)
+	}
+}
+
+func TestIsHeader(t *testing.T) {
+	if !isHeader([]string{"USER", "PID"}, []string{"root", "1"}) {
+		t.Error("Upper-case header not detected")
+	}
+	if !isHeader([]string{"name", "age"}, []string{"alice", "30"}) {
+		t.Error("Header of numeric column not detected")
+	}
+	if isHeader([]string{"alice", "bob"}, []string{"carol", "dave"}) {
+		t.Error("Incorrect header detected")
+	}
+	if isHeader([]string{"a", "1"}, []string{"b", "2"}) {
+		t.Error("Incorrect numeric header detected")
+	}
+}
+
+func TestFindColumn(t *testing.T) {
+	header := []string{"NAME", "AGE"}
+	rows := [][]string{{"alice", "30", "x"}}
+	for name, expected := range map[string]int{"age": 1, "NAME": 0, "3": 2} {
+		column, err := findColumn(header, rows, name)
+		if err != nil || column != expected {
+			t.Error("Incorrect column for " + name)
+		}
+	}
+	for _, name := range []string{"city", "0", "4"} {
+		if _, err := findColumn(header, rows, name); err == nil {
+			t.Error("Invalid column " + name + " not detected")
+		}
+	}
+}
+
+func TestTableItemList(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile("[a-z]+")
+	config.table = tableCsv
+	config.column = "city"
+	list, err := NewTableItemList([]string{"NAME,CITY", "bob,paris", "alice,rome"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list.pinned) != 1 || list.pinned[0] != "NAME   CITY" || len(list.items) != 2 {
+		t.Error("Incorrect header", list.pinned)
+	}
+	item := list.items[1]
+	if item.original != "alice  rome" || item.match != "rome" || item.number != 3 {
+		t.Error("Incorrect line", item.original, item.match)
+	}
+	placeholders := item.Placeholders()
+	if placeholders["1"] != "alice" || placeholders["CITY"] != "rome" || placeholders["n"] != "3" {
+		t.Error("Incorrect placeholders", placeholders)
+	}
+}
+
+func TestTableWideCharacters(t *testing.T) {
+	config = &Config{}
+	config.table = tableCsv
+	list, err := NewTableItemList([]string{"NAME,CITY", "東京,tokyo", "bob,paris"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wide characters take two columns
+	if list.items[0].original != "東京  tokyo" || list.items[1].original != "bob   paris" {
+		t.Error("Incorrect alignment of wide characters", list.items[0].original, list.items[1].original)
+	}
+}
+
+func TestTableEmptyFields(t *testing.T) {
+	config = &Config{}
+	config.pattern = regexp.MustCompile(".+")
+	config.table = tableTsv
+	config.column = "1"
+	list, err := NewTableItemList([]string{"A\tB\tC", "1\t2\t3", "\t2\t3", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty fields and missing columns have no match instead of matching the whole line
+	if len(list.items[1].fields) != 3 || list.items[1].fields[0] != "" || list.items[1].HasMatch() {
+		t.Error("Incorrect empty first field", list.items[1].fields)
+	}
+	if list.items[0].match != "1" || list.items[2].match != "1" {
+		t.Error("Incorrect match of column")
+	}
+
+	config.column = "C"
+	list, _ = NewTableItemList([]string{"A\tB\tC", "1\t2\t3", "1"})
+	if list.items[0].match != "3" || list.items[1].HasMatch() {
+		t.Error("Incorrect line without the column")
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"sort"
+	"strings"
+	"github.com/gdamore/tcell/v2"
+)
+
+// Styles are given as color tags fg:bg:attributes, see https://github.com/rivo/tview/blob/master/doc.go
+type Theme struct {
+	match string
+	group string
+	selected string
+	status string
+	error string
+	output string
+	executed string
+	failed string
+	number string
+	header string
+	invalid string
+}
+
+var themes = map[string]*Theme{
+	"default": {
+		match: "::r",
+		group: "",
+		selected: "::r",
+		status: "",
+		error: "red::b",
+		output: "",
+		executed: "green::b",
+		failed: "red::b",
+		number: "::d",
+		header: "::b",
+		invalid: "::d",
+	},
+	"dark": {
+		match: "black:yellow:b",
+		group: "yellow",
+		selected: "white:navy:b",
+		status: "white:darkslategray",
+		error: "red::b",
+		output: "silver",
+		executed: "lime::b",
+		failed: "red::b",
+		number: "gray",
+		header: "::b",
+		invalid: "gray",
+	},
+	"light": {
+		match: "white:blue:b",
+		group: "blue",
+		selected: "black:lightgray",
+		status: "black:gainsboro",
+		error: "maroon::b",
+		output: "black",
+		executed: "green::b",
+		failed: "maroon::b",
+		number: "gray",
+		header: "::b",
+		invalid: "gray",
+	},
+	"high-contrast": {
+		match: "black:yellow:bu",
+		group: "yellow::u",
+		selected: "black:white:b",
+		status: "white:black:b",
+		error: "white:red:b",
+		output: "white",
+		executed: "lime::b",
+		failed: "red::b",
+		number: "white::b",
+		header: "white::bu",
+		invalid: "::d",
+	},
+}
+
+// Theme in use, which is set by the configuration
+var theme = themes["default"]
+
+func NewTheme(name string, overrides map[string]map[string]string, noColor bool) (*Theme, error) {
+	// Themes of the configuration file are based on the default theme
+	available := maps.Clone(themes)
+	for custom, styles := range overrides {
+		base, ok := available[custom]
+		if !ok {
+			base = available["default"]
+		}
+		t := *base
+		for element, style := range styles {
+			err := t.set(element, style)
+			if err != nil {
+				return nil, err
+			}
+		}
+		available[custom] = &t
+	}
+
+	t, ok := available[name]
+	if !ok {
+		return nil, errors.New("Invalid theme " + name + ", use one of " + strings.Join(ThemeNames(available), ", "))
+	}
+
+	if noColor {
+		return t.monochrome(), nil
+	}
+	return t, nil
+}
+
+func ThemeNames(available map[string]*Theme) []string {
+	names := []string{}
+	for name := range available {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *Theme) set(element string, style string) error {
+	if !isValidStyle(style) {
+		return errors.New("Invalid style " + style + " for " + element)
+	}
+
+	switch element {
+	case "match":
+		t.match = style
+	case "group":
+		t.group = style
+	case "selected":
+		t.selected = style
+	case "status":
+		t.status = style
+	case "error":
+		t.error = style
+	case "output":
+		t.output = style
+	case "executed":
+		t.executed = style
+	case "failed":
+		t.failed = style
+	case "number":
+		t.number = style
+	case "header":
+		t.header = style
+	case "invalid":
+		t.invalid = style
+	default:
+		return errors.New("Invalid theme element " + element)
+	}
+	return nil
+}
+
+func (t *Theme) monochrome() *Theme {
+	// Keep the attributes only
+	return &Theme{
+		match: withoutColors(t.match, "r"),
+		group: withoutColors(t.group, ""),
+		selected: withoutColors(t.selected, "r"),
+		status: withoutColors(t.status, ""),
+		error: withoutColors(t.error, "b"),
+		output: withoutColors(t.output, ""),
+		executed: withoutColors(t.executed, ""),
+		failed: withoutColors(t.failed, ""),
+		number: withoutColors(t.number, ""),
+		header: withoutColors(t.header, "b"),
+		invalid: withoutColors(t.invalid, "d"),
+	}
+}
+
+// Highlights the match, where active is the style of the input before it
+func (t *Theme) Match(text string, active string) string {
+	// The attributes of the input are reset within the match
+	fg, bg, _ := splitStyle(t.match)
+	return "[::-][" + t.match + "]" + text + closingTag(fg + ":" + bg + ":-", active)
+}
+
+func (t *Theme) Group(text string, active string) string {
+	if t.group == "" || text == "" {
+		return text
+	}
+	return "[" + t.group + "]" + text + closingTag(t.group, active)
+}
+
+func (t *Theme) Apply(style string, text string) string {
+	if style == "" {
+		return text
+	}
+	return "[" + style + "]" + text + closingTag(style, "")
+}
+
+// Restores only the colors and attributes changed by the style to the active ones
+func closingTag(style string, active string) string {
+	fg, bg, attributes := splitStyle(style)
+	activeFg, activeBg, activeAttributes := splitStyle(active)
+	tag := "[" + restoreStyle(fg, activeFg) + ":" + restoreStyle(bg, activeBg) + ":" + restoreStyle(attributes, "") + "]"
+	if attributes != "" && activeAttributes != "" {
+		tag += "[::" + activeAttributes + "]"
+	}
+	return tag
+}
+
+func restoreStyle(changed string, active string) string {
+	if changed == "" {
+		return ""
+	} else if active == "" {
+		return "-"
+	}
+	return active
+}
+
+// Returns the style fg:bg:attributes in effect at the end of the display
+func activeStyle(display string) string {
+	fg, bg, attributes := "", "", ""
+	for _, tag := range reStyleTag.FindAllStringSubmatch(display, -1) {
+		fg = updateStyle(fg, tag[1])
+		bg = updateStyle(bg, tag[2])
+		if tag[3] == "-" {
+			attributes = ""
+		} else {
+			for _, attribute := range tag[3] {
+				lower := strings.ToLower(string(attribute))
+				attributes = strings.ReplaceAll(attributes, lower, "")
+				if string(attribute) == lower {
+					attributes += lower
+				}
+			}
+		}
+	}
+	if fg == "" && bg == "" && attributes == "" {
+		return ""
+	}
+	return fg + ":" + bg + ":" + attributes
+}
+
+func updateStyle(current string, value string) string {
+	if value == "-" {
+		return ""
+	} else if value != "" {
+		return value
+	}
+	return current
+}
+
+func splitStyle(style string) (string, string, string) {
+	parts := strings.SplitN(style + "::", ":", 3)
+	return parts[0], parts[1], strings.TrimRight(parts[2], ":")
+}
+
+func withoutColors(style string, fallback string) string {
+	_, _, attributes := splitStyle(style)
+	if attributes == "" {
+		attributes = fallback
+	}
+	if attributes == "" {
+		return ""
+	}
+	return "::" + attributes
+}
+
+func isValidStyle(style string) bool {
+	fg, bg, attributes := splitStyle(style)
+	for _, color := range []string{fg, bg} {
+		if color != "" && color != "-" && color != "default" && tcell.GetColor(color) == tcell.ColorDefault {
+			return false
+		}
+	}
+	return attributes == "-" || strings.Trim(attributes, "bdilrsu") == ""
+}
+
+func ParseStyle(style string) tcell.Style {
+	fg, bg, attributes := splitStyle(style)
+	result := tcell.StyleDefault
+	if fg != "" {
+		result = result.Foreground(tcell.GetColor(fg))
+	}
+	if bg != "" {
+		result = result.Background(tcell.GetColor(bg))
+	}
+	for _, attribute := range attributes {
+		switch attribute {
+		case 'b':
+			result = result.Bold(true)
+		case 'd':
+			result = result.Dim(true)
+		case 'i':
+			result = result.Italic(true)
+		case 'l':
+			result = result.Blink(true)
+		case 'r':
+			result = result.Reverse(true)
+		case 's':
+			result = result.StrikeThrough(true)
+		case 'u':
+			result = result.Underline(true)
+		}
+	}
+	return result
+}
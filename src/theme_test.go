@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestThemes(t *testing.T) {
+	for name, builtin := range themes {
+		for _, style := range []string{builtin.match, builtin.group, builtin.selected, builtin.status, builtin.error,
+			builtin.output, builtin.executed, builtin.failed} {
+			if !isValidStyle(style) {
+				t.Error("Invalid style", style, "in theme", name)
+			}
+		}
+	}
+
+	if themes["default"].Match("match", "") != "[::-][::r]match[::-]" {
+		t.Error("Incorrect default match")
+	}
+	if themes["dark"].Match("match", "") != "[::-][black:yellow:b]match[-:-:-]" {
+		t.Error("Incorrect colored match")
+	}
+	if themes["default"].Group("group", "") != "group" || themes["dark"].Group("group", "") != "[yellow]group[-::]" {
+		t.Error("Incorrect group")
+	}
+	if themes["dark"].Match("match", "maroon::b") != "[::-][black:yellow:b]match[maroon:-:-][::b]" {
+		t.Error("Incorrect colored match within colored input")
+	}
+	if themes["dark"].Group("group", "maroon:navy:") != "[yellow]group[maroon::]" {
+		t.Error("Incorrect group within colored input")
+	}
+}
+
+func TestActiveStyle(t *testing.T) {
+	if activeStyle("plain [text[] here") != "" {
+		t.Error("Incorrect style without color tags")
+	}
+	if activeStyle("a [maroon::b]red[-:-:] [:navy]blue") != ":navy:b" {
+		t.Error("Incorrect style after color tags")
+	}
+	if activeStyle("[::bu]a[::U]b") != "::b" {
+		t.Error("Incorrect style after removed attributes")
+	}
+}
+
+func TestCustomTheme(t *testing.T) {
+	custom, err := NewTheme("custom", map[string]map[string]string{"custom": {"match": "red::u"}}, false)
+	if err != nil || custom.match != "red::u" || custom.selected != themes["default"].selected {
+		t.Error("Incorrect custom theme")
+	}
+
+	_, err = NewTheme("foobar", map[string]map[string]string{}, false)
+	if err == nil {
+		t.Error("Incorrect unknown theme")
+	}
+
+	_, err = NewTheme("custom", map[string]map[string]string{"custom": {"match": "nocolor::u"}}, false)
+	if err == nil {
+		t.Error("Incorrect invalid color")
+	}
+
+	_, err = NewTheme("custom", map[string]map[string]string{"custom": {"foobar": "red"}}, false)
+	if err == nil {
+		t.Error("Incorrect unknown element")
+	}
+
+	custom, err = NewTheme("default", map[string]map[string]string{"default": {"match": "default:-:b"}}, false)
+	if err != nil || custom.match != "default:-:b" {
+		t.Error("Incorrect default colors")
+	}
+	if themes["default"].match != "::r" || themes["custom"] != nil {
+		t.Error("Incorrect change of the built-in themes")
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	monochrome, _ := NewTheme("high-contrast", map[string]map[string]string{}, true)
+	if monochrome.match != "::bu" || monochrome.group != "::u" || monochrome.status != "::b" || monochrome.output != "" {
+		t.Error("Incorrect theme without colors")
+	}
+	if monochrome.Match("match", "") != "[::-][::bu]match[::-]" {
+		t.Error("Incorrect match without colors")
+	}
+}
@@ -220,12 +220,269 @@ function run() {
         echo -e "test1 foobar\ntest2 [::-][::r]$USER[::-]" > test/EXPECT_$1
         diff test/RESULT_$1 test/EXPECT_$1
         ;;
+    36)
+        echo -e "#!/bin/bash\nsleep 10" > test/SCRIPT_$1
+        chmod +x test/SCRIPT_$1
+        echo -e "test1" | ./lisst --timeout 100ms test test/SCRIPT_$1 || echo "$?" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "124" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    37)
+        ! echo -e "test1" | ./lisst --timeout foo test 2> test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "Invalid timeout" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    38)
+        echo -e "it's \$HOME" | ./lisst --shell --line "echo {} | tr a-z A-Z" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "IT'S \$HOME" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    39)
+        echo -e "key=value here\nfoo" | ./lisst "(\w+)=(\w+)" sh -c 'echo $LISST_MATCH $LISST_GROUP_2 $LISST_INDEX "$LISST_LINE"' {} > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "key value 1 key=value here" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    40)
+        echo -e "test1 test2\ntest3" | ./lisst --dry-run "test[1-9]" rm -f {} > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "rm -f test1" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    41)
+        echo -e "#!/bin/bash\necho \"failed \$1\" 1>&2\nexit 3" > test/SCRIPT_$1
+        chmod +x test/SCRIPT_$1
+        echo -e "test1" | ./lisst --show-output test test/SCRIPT_$1 2> test/RESULT_$1 || echo "$?" >> test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "failed test\n3" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    42)
+        echo -e "test1 it's" | XDG_STATE_HOME=test ./lisst --record "test[1-9] .*" echo > /dev/null
+        test $? -ne 0 && exit 1
+        grep -qF "\"match\":\"test1 it's\",\"executed\":\"echo 'test1 it'" test/lisst/history
+        ;;
+    43)
+        mkdir -p test/lisst
+        echo -e "# Comment\nkeys = vi\nbind.history = n" > test/lisst/config
+        ! echo -e "test1" | XDG_CONFIG_HOME=test ./lisst test 2> test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "Key n is bound to both next-match and history" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    44)
+        echo -e "this text is \033[0;31mred\033[0m" | NO_COLOR=1 ./lisst red > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "this text is [::-][::r]red[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    45)
+        echo -e "test1 test2" | ./lisst --theme dark "t(es)t" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "[yellow]t[-::][::-][black:yellow:b]es[-:-:-][yellow]t[-::]1 test2" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    46)
+        echo -e "a\nb1\nc\nd2" | ./lisst --filter --line-numbers "[0-9]" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::d]2[::-] b[::-][::r]1[::-]\n[::d]4[::-] d[::-][::r]2[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    47)
+        echo -e "a\nb1\nc" | ./lisst --filter --dry-run "[0-9]" sed -n {n}p input.txt > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "sed -n 2p input.txt" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    48)
+        echo -e "10:00 b\n9:05 a" | ./lisst --sort --time > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::-][::r]9:05[::-] a\n[::-][::r]10:00[::-] b" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    49)
+        echo -e "v1.10\nv1.9" | ./lisst --sort-mode version ".+" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::-][::r]v1.9[::-]\n[::-][::r]v1.10[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    50)
+        echo -e "a.go:1\nb.go:2\na.go:3" | ./lisst --unique "^\w+\.go" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::-][::r]a.go[::-]:1 [::d]×2[::-]\n[::-][::r]b.go[::-]:2" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    51)
+        echo -e "a.go:1\nb.go:2\na.go:3" | ./lisst --group "^\w+\.go" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::-][::r]a.go[::-] [::d]×2[::-]\n  [::-][::r]a.go[::-]:1\n  [::-][::r]a.go[::-]:3\n[::-][::r]b.go[::-] [::d]×1[::-]\n  [::-][::r]b.go[::-]:2" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    52)
+        echo -e "a.go:1\nb.go:2" | ./lisst --group --dry-run "^\w+\.go" echo > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "echo a.go" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    53)
+        echo -e "abc1234 fix\nplain\nMerge abc9999" | ./lisst --invert-filter --exclude "^Merge" "[0-9a-f]{7}" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "plain" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    54)
+        echo -e "Merge abc9999" | ./lisst --exclude "^Merge" "[0-9a-f]{7}" 2> test/RESULT_$1
+        test $? -ne 1 && exit 1
+        echo "All lines excluded" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    55)
+        echo -e "name,age\nalice,30\nbob,4" | ./lisst --csv --column age "[0-9]+" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::b]name   age[::-]\nalice  [::-][::r]30[::-]\nbob    [::-][::r]4[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    56)
+        echo -e "USER  PID  COMMAND\nroot  12   sleep 5\nuser  345  vi 1.txt" | ./lisst --columns --column PID --dry-run "[0-9]+" echo {USER} {3} {n} > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "echo root sleep 5 2" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    57)
+        echo -e "a\t1\nbb\t2" | ./lisst --tsv --column 2 "[0-9]" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "a   [::-][::r]1[::-]\nbb  [::-][::r]2[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    58)
+        echo -e "name,age\nalice,30" | ./lisst --csv --column city "[0-9]+" 2> test/RESULT_$1
+        test $? -ne 1 && exit 1
+        echo "Invalid column city" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    59)
+        echo -e '{"level":"info","id":"r1"}\nnot json\n{"level":"error"}' | ./lisst --display "{.level}:" --match-field .id > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "info:  [::-][::r]r1[::-]\n[::d]not json[::-]\nerror:" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    60)
+        echo -e '{"id":7,"user":{"name":"ann"}}' | ./lisst --jsonl --match-field .id --dry-run echo {.user.name} > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "echo ann" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    61)
+        echo -e "NAME ID\nb 2\na 1\nc" | ./lisst --header 1 --sort --filter "[0-9]" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::b]NAME ID[::-]\na [::-][::r]1[::-]\nb [::-][::r]2[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    62)
+        echo -e "total 2\nname size\nb 2\na 1" | ./lisst --header 2 --columns --column size --line-numbers "[0-9]" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "  [::b]total 2[::-]\n  [::b]name  size[::-]\n[::d]3[::-] b     [::-][::r]2[::-]\n[::d]4[::-] a     [::-][::r]1[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    63)
+        echo -e "a1" | ./lisst --header 0 "[0-9]" 2> test/RESULT_$1
+        test $? -ne 1 && exit 1
+        echo "Invalid number of header lines" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    64)
+        printf 'a\nb.txt\0c.txt\0' | ./lisst -0 --dry-run "(?s).+" echo > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "echo a\nb.txt" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    65)
+        printf 'a\nb.txt\0c.txt' | ./lisst --null "[a-z]+[.]txt" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "a␤[::-][::r]b.txt[::-]\n[::-][::r]c.txt[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    66)
+        printf 'id 1\nfoo\n---\nid 2\n' | ./lisst --delimiter "\\n---\\n" --multi-line "id [0-9]" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::-][::r]id 1[::-]\nfoo\n[::-][::r]id 2[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    67)
+        printf 'a\x00b\xff1\n' | ./lisst "1$" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo 'a\x00b\xff[::-][::r]1[::-]' > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    68)
+        head -c 100000 /dev/zero | tr '\0' x | ./lisst --dry-run "x+" wc -c > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        test $(wc -c < test/RESULT_$1) -eq 100007
+        ;;
+    69)
+        ./lisst "[0-9]" < / 2> test/RESULT_$1 || true
+        grep -q "Error reading input" test/RESULT_$1
+        ;;
+    70)
+        echo -e "a1\n\nb\n  \nc2" | ./lisst --keep-empty --line-numbers "[0-9]" > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "[::d]1[::-] a[::-][::r]1[::-]\n  \n[::d]3[::-] b\n  \n[::d]5[::-] c[::-][::r]2[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    71)
+        echo -e "\n  \n" | ./lisst --keep-empty "[0-9]" 2> test/RESULT_$1
+        test $? -ne 1 && exit 1
+        echo "Empty input" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    72)
+        echo -e "a1\nb2" > test/input1.txt
+        echo -e "c3" > test/input2.txt
+        ./lisst -f test/input1.txt --file test/input2.txt --sort-rev --dry-run "[0-9]" echo {src} {n} < /dev/null > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo "echo test/input2.txt 1" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    73)
+        ./lisst -c "echo a1; echo b2" "[0-9]" < /dev/null > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "a[::-][::r]1[::-]\nb[::-][::r]2[::-]" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    74)
+        ./lisst -f test/missing.txt "[0-9]" < /dev/null 2> test/RESULT_$1
+        test $? -ne 1 && exit 1
+        echo "Error reading input: open test/missing.txt: no such file or directory" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    75)
+        mkdir -p test/lisst
+        echo -e "keys = vi\nbind.quit = q\nbroken" > test/lisst/config
+        XDG_CONFIG_HOME=test ./lisst --help > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        grep -qF "[q] or [Esc]        Quit" test/RESULT_$1
+        ;;
+    76)
+        printf 'a\nb.txt\0c\nd.txt\0' | ./lisst -0 --line --dry-run --exclude "c.d" echo > test/RESULT_$1
+        test $? -ne 0 && exit 1
+        echo -e "echo a\nb.txt" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
+    77)
+        ./lisst -c "echo a1; sleep 10" --timeout 100ms "[0-9]" < /dev/null 2> test/RESULT_$1 > /dev/null
+        test $? -ne 0 && exit 1
+        echo "Error reading input: Timeout after 100ms" > test/EXPECT_$1
+        diff test/RESULT_$1 test/EXPECT_$1
+        ;;
     esac
 }
 
 if [ $# -eq 0 ]; then
     result=0
-    for i in {1..35}; do
+    for i in {1..77}; do
         echo "Test $i"
         run $i || { result=1; echo "   FAILED"; }
     done